@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"monkey/token"
+	"strconv"
 	"strings"
 )
 
@@ -38,6 +39,15 @@ func (p *Program) TokenLiteral() string {
 		return ""
 	}
 }
+
+// 子节点的字符串表示。解析出错时子节点可能为 nil，此时为空字符串
+func nodeString(node Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.String()
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -67,15 +77,67 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// import 声明，两种形式：
+//
+//	import "path/to/mod.mk" as m;
+//	import { a, b } from "path/to/mod.mk";
+type ImportStatement struct {
+	Token token.Token // the token.IMPORT token
+	Path  *StringLiteral
+	Alias *Identifier   // 整体导入时模块绑定的名字
+	Names []*Identifier // 选择性导入的名字，为空表示整体导入
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(is.TokenLiteral() + " ")
+	if len(is.Names) > 0 {
+		names := []string{}
+		for _, n := range is.Names {
+			names = append(names, n.String())
+		}
+		out.WriteString("{ " + strings.Join(names, ", ") + " } from ")
+	}
+	if is.Path != nil {
+		out.WriteString(strconv.Quote(is.Path.Value))
+	}
+	if len(is.Names) == 0 && is.Alias != nil {
+		out.WriteString(" as " + is.Alias.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// export 声明，导出模块顶层的 let 绑定
+type ExportStatement struct {
+	Token     token.Token // the token.EXPORT token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	if es.Statement == nil {
+		return es.TokenLiteral() + " "
+	}
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
 // 代码块声明，被{} 包裹的都是代码块
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	End        token.Token // the } token
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 func (bs *BlockStatement) String() string {
+	if bs == nil {
+		return ""
+	}
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
 		out.WriteString(s.String())
@@ -109,7 +171,12 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
-func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) String() string {
+	if i == nil {
+		return ""
+	}
+	return i.Value
+}
 
 // 表达式类型的声明（表达式也是一种特殊的声明）
 type ExpressionStatement struct {
@@ -136,6 +203,16 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+// 浮点数字面量，让脚本可以直接写出 json_parse 等产生的 FLOAT 值
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 // 前缀表达式
 type PrefixExpression struct {
 	Token    token.Token // The prefix token, e.g. !
@@ -149,7 +226,7 @@ func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
 	out.WriteString(pe.Operator)
-	out.WriteString(pe.Right.String())
+	out.WriteString(nodeString(pe.Right))
 	out.WriteString(")")
 	return out.String()
 }
@@ -167,9 +244,9 @@ func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
-	out.WriteString(oe.Left.String())
+	out.WriteString(nodeString(oe.Left))
 	out.WriteString(" " + oe.Operator + " ")
-	out.WriteString(oe.Right.String())
+	out.WriteString(nodeString(oe.Right))
 	out.WriteString(")")
 	return out.String()
 }
@@ -196,7 +273,7 @@ func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
-	out.WriteString(ie.Condition.String())
+	out.WriteString(nodeString(ie.Condition))
 	out.WriteString(" ")
 	out.WriteString(ie.Consequence.String())
 	if ie.Alternative != nil {
@@ -229,11 +306,35 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// 宏定义，只能出现在顶层的 let 语句中，在求值前展开
+type MacroLiteral struct {
+	Token      token.Token // The 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+	return out.String()
+}
+
 // 函数调用表达式
 type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	End       token.Token // the ')' token
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -242,9 +343,9 @@ func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	args := []string{}
 	for _, a := range ce.Arguments {
-		args = append(args, a.String())
+		args = append(args, nodeString(a))
 	}
-	out.WriteString(ce.Function.String())
+	out.WriteString(nodeString(ce.Function))
 	out.WriteString("(")
 	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
@@ -263,6 +364,7 @@ func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	End      token.Token // the ']' token
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
@@ -271,7 +373,7 @@ func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	elements := []string{}
 	for _, el := range al.Elements {
-		elements = append(elements, el.String())
+		elements = append(elements, nodeString(el))
 	}
 	out.WriteString("[")
 	out.WriteString(strings.Join(elements, ", "))
@@ -291,9 +393,53 @@ func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
-	out.WriteString(ie.Left.String())
+	out.WriteString(nodeString(ie.Left))
 	out.WriteString("[")
-	out.WriteString(ie.Index.String())
+	out.WriteString(nodeString(ie.Index))
+	out.WriteString("])")
+	return out.String()
+}
+
+// 成员访问，如 m.name
+type MemberExpression struct {
+	Token    token.Token // The . token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	return "(" + nodeString(me.Object) + "." + nodeString(me.Property) + ")"
+}
+
+// 切片表达式，如 array[1:3]、str[::-1]，省略的部分为 nil
+type SliceExpression struct {
+	Token token.Token // The [ token
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(nodeString(se.Left))
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
 	out.WriteString("])")
 	return out.String()
 }
@@ -301,6 +447,28 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // key 在源码中的顺序
+	End   token.Token  // the '}' token
+}
+
+// 按源码顺序返回所有 key，不在 Keys 里的 key 排在最后
+func (hl *HashLiteral) OrderedKeys() []Expression {
+	keys := make([]Expression, 0, len(hl.Pairs))
+	seen := make(map[Expression]bool, len(hl.Keys))
+	for _, key := range hl.Keys {
+		if _, ok := hl.Pairs[key]; ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) < len(hl.Pairs) {
+		for key := range hl.Pairs {
+			if !seen[key] {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -308,8 +476,8 @@ func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.OrderedKeys() {
+		pairs = append(pairs, nodeString(key)+":"+nodeString(hl.Pairs[key]))
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -25,3 +25,22 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+// 解析出错时节点的部分子节点可能为 nil，String 不应该 panic
+func TestStringWithMissingNodes(t *testing.T) {
+	tests := []struct {
+		node     Node
+		expected string
+	}{
+		{&PrefixExpression{Operator: "-"}, "(-)"},
+		{&InfixExpression{Operator: "+", Right: &Identifier{Value: "b"}}, "( + b)"},
+		{&IndexExpression{Left: &Identifier{Value: "a"}}, "(a[])"},
+		{&CallExpression{Arguments: []Expression{nil}}, "()"},
+		{&ExportStatement{Token: token.Token{Type: token.EXPORT, Literal: "export"}}, "export "},
+	}
+	for _, tt := range tests {
+		if got := tt.node.String(); got != tt.expected {
+			t.Errorf("wrong String() for %T. got=%q, want=%q", tt.node, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package ast
+
+// 修改节点的函数，返回替换原节点的新节点
+type ModifierFunc func(Node) Node
+
+// 深度优先遍历 node，先修改所有子节点，再对 node 自身调用 modifier。
+// 复合节点会被复制后再修改，原来的语法树保持不变，
+// 因此同一段代码（如函数体中的 quote）可以被反复修改。
+// modifier 返回的节点类型不适合放在原位置时（如把语句替换为表达式），该位置被置为 nil
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	// Statements
+	case *Program:
+		program := *node
+		program.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&program)
+	case *LetStatement:
+		stmt := *node
+		stmt.Name = modifyIdentifier(node.Name, modifier)
+		stmt.Value = modifyExpression(node.Value, modifier)
+		return modifier(&stmt)
+	case *ImportStatement:
+		stmt := *node
+		stmt.Names = modifyIdentifiers(node.Names, modifier)
+		if node.Path != nil {
+			stmt.Path, _ = Modify(node.Path, modifier).(*StringLiteral)
+		}
+		stmt.Alias = modifyIdentifier(node.Alias, modifier)
+		return modifier(&stmt)
+	case *ExportStatement:
+		stmt := *node
+		if node.Statement != nil {
+			stmt.Statement, _ = Modify(node.Statement, modifier).(*LetStatement)
+		}
+		return modifier(&stmt)
+	case *BlockStatement:
+		block := *node
+		block.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&block)
+	case *ReturnStatement:
+		stmt := *node
+		stmt.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+		return modifier(&stmt)
+	case *ExpressionStatement:
+		stmt := *node
+		stmt.Expression = modifyExpression(node.Expression, modifier)
+		return modifier(&stmt)
+
+	// Expressions
+	case *PrefixExpression:
+		exp := *node
+		exp.Right = modifyExpression(node.Right, modifier)
+		return modifier(&exp)
+	case *InfixExpression:
+		exp := *node
+		exp.Left = modifyExpression(node.Left, modifier)
+		exp.Right = modifyExpression(node.Right, modifier)
+		return modifier(&exp)
+	case *IfExpression:
+		exp := *node
+		exp.Condition = modifyExpression(node.Condition, modifier)
+		exp.Consequence = modifyBlock(node.Consequence, modifier)
+		exp.Alternative = modifyBlock(node.Alternative, modifier)
+		return modifier(&exp)
+	case *FunctionLiteral:
+		fn := *node
+		fn.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		fn.Body = modifyBlock(node.Body, modifier)
+		return modifier(&fn)
+	case *MacroLiteral:
+		macro := *node
+		macro.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		macro.Body = modifyBlock(node.Body, modifier)
+		return modifier(&macro)
+	case *CallExpression:
+		call := *node
+		call.Function = modifyExpression(node.Function, modifier)
+		call.Arguments = modifyExpressions(node.Arguments, modifier)
+		return modifier(&call)
+	case *ArrayLiteral:
+		array := *node
+		array.Elements = modifyExpressions(node.Elements, modifier)
+		return modifier(&array)
+	case *IndexExpression:
+		exp := *node
+		exp.Left = modifyExpression(node.Left, modifier)
+		exp.Index = modifyExpression(node.Index, modifier)
+		return modifier(&exp)
+	case *MemberExpression:
+		exp := *node
+		exp.Object = modifyExpression(node.Object, modifier)
+		exp.Property = modifyIdentifier(node.Property, modifier)
+		return modifier(&exp)
+	case *SliceExpression:
+		exp := *node
+		exp.Left = modifyExpression(node.Left, modifier)
+		exp.Start = modifyExpression(node.Start, modifier)
+		exp.End = modifyExpression(node.End, modifier)
+		exp.Step = modifyExpression(node.Step, modifier)
+		return modifier(&exp)
+	case *HashLiteral:
+		hash := *node
+		hash.Pairs = make(map[Expression]Expression, len(node.Pairs))
+		hash.Keys = make([]Expression, 0, len(node.Pairs))
+		for _, key := range node.OrderedKeys() {
+			newKey := modifyExpression(key, modifier)
+			hash.Pairs[newKey] = modifyExpression(node.Pairs[key], modifier)
+			hash.Keys = append(hash.Keys, newKey)
+		}
+		return modifier(&hash)
+	}
+	// Identifier、IntegerLiteral 等没有子节点的节点
+	return modifier(node)
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if exp == nil {
+		return nil
+	}
+	modified, _ := Modify(exp, modifier).(Expression)
+	return modified
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	modified, _ := Modify(ident, modifier).(*Identifier)
+	return modified
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	modified, _ := Modify(block, modifier).(*BlockStatement)
+	return modified
+}
+
+func modifyStatements(statements []Statement, modifier ModifierFunc) []Statement {
+	modified := make([]Statement, len(statements))
+	for i, stmt := range statements {
+		if stmt != nil {
+			modified[i], _ = Modify(stmt, modifier).(Statement)
+		}
+	}
+	return modified
+}
+
+func modifyExpressions(expressions []Expression, modifier ModifierFunc) []Expression {
+	modified := make([]Expression, len(expressions))
+	for i, exp := range expressions {
+		modified[i] = modifyExpression(exp, modifier)
+	}
+	return modified
+}
+
+func modifyIdentifiers(identifiers []*Identifier, modifier ModifierFunc) []*Identifier {
+	if identifiers == nil {
+		return nil
+	}
+	modified := make([]*Identifier, len(identifiers))
+	for i, ident := range identifiers {
+		modified[i] = modifyIdentifier(ident, modifier)
+	}
+	return modified
+}
@@ -0,0 +1,105 @@
+package ast
+
+import "monkey/token"
+
+// 源码中的位置，行和列从 1 开始，列按字符计数，和 token 的位置相同
+type Position struct {
+	Line, Column int
+}
+
+// p 是否在 other 之前
+func (p Position) Before(other Position) bool {
+	return p.Line < other.Line || p.Line == other.Line && p.Column < other.Column
+}
+
+// 节点的起始位置，规则和 Pos 相同
+func PositionOf(node Node) Position {
+	line, column := Pos(node)
+	return Position{line, column}
+}
+
+// token 的位置
+func TokenPosition(tok token.Token) Position {
+	return Position{tok.Line, tok.Column}
+}
+
+// 节点在源码中的起始位置，即节点第一个 token 的行和列。
+// 中缀、调用、下标等表达式的 Token 是运算符，起始位置取左侧的表达式
+func Pos(node Node) (line, column int) {
+	switch node := node.(type) {
+	case *ExpressionStatement:
+		if node.Expression != nil {
+			return Pos(node.Expression)
+		}
+		return node.Token.Line, node.Token.Column
+	case *InfixExpression:
+		return Pos(node.Left)
+	case *CallExpression:
+		return Pos(node.Function)
+	case *IndexExpression:
+		return Pos(node.Left)
+	case *SliceExpression:
+		return Pos(node.Left)
+	case *MemberExpression:
+		return Pos(node.Object)
+	case *Program:
+		if len(node.Statements) > 0 {
+			return Pos(node.Statements[0])
+		}
+		return 1, 1
+	case nil:
+		return 0, 0
+	}
+	tok := tokenOf(node)
+	return tok.Line, tok.Column
+}
+
+func tokenOf(node Node) token.Token {
+	switch node := node.(type) {
+	case *LetStatement:
+		return node.Token
+	case *ImportStatement:
+		return node.Token
+	case *ExportStatement:
+		return node.Token
+	case *BlockStatement:
+		return node.Token
+	case *ReturnStatement:
+		return node.Token
+	case *ExpressionStatement:
+		return node.Token
+	case *Identifier:
+		return node.Token
+	case *IntegerLiteral:
+		return node.Token
+	case *FloatLiteral:
+		return node.Token
+	case *Boolean:
+		return node.Token
+	case *StringLiteral:
+		return node.Token
+	case *PrefixExpression:
+		return node.Token
+	case *InfixExpression:
+		return node.Token
+	case *IfExpression:
+		return node.Token
+	case *FunctionLiteral:
+		return node.Token
+	case *MacroLiteral:
+		return node.Token
+	case *CallExpression:
+		return node.Token
+	case *ArrayLiteral:
+		return node.Token
+	case *IndexExpression:
+		return node.Token
+	case *MemberExpression:
+		return node.Token
+	case *SliceExpression:
+		return node.Token
+	case *HashLiteral:
+		return node.Token
+	}
+	return token.Token{}
+}
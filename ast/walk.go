@@ -0,0 +1,141 @@
+package ast
+
+// Walk 遍历语法树时，对每个节点调用 Visit。
+// 返回的 w 不为 nil 时，Walk 用 w 遍历该节点的子节点，最后调用 w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// 深度优先遍历语法树，和 go/ast 的 Walk 相同。
+// 子节点按源码中出现的顺序访问，值为 nil 的子节点会被跳过
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// Statements
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		walkExpression(v, n.Value)
+	case *ImportStatement:
+		for _, name := range n.Names {
+			Walk(v, name)
+		}
+		if n.Path != nil {
+			Walk(v, n.Path)
+		}
+		if n.Alias != nil {
+			Walk(v, n.Alias)
+		}
+	case *ExportStatement:
+		if n.Statement != nil {
+			Walk(v, n.Statement)
+		}
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+
+	// Expressions
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *Boolean, *StringLiteral:
+		// 没有子节点
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *FunctionLiteral:
+		walkIdentifiers(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *MacroLiteral:
+		walkIdentifiers(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *MemberExpression:
+		walkExpression(v, n.Object)
+		if n.Property != nil {
+			Walk(v, n.Property)
+		}
+	case *SliceExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Start)
+		walkExpression(v, n.End)
+		walkExpression(v, n.Step)
+	case *HashLiteral:
+		for _, key := range n.OrderedKeys() {
+			walkExpression(v, key)
+			walkExpression(v, n.Pairs[key])
+		}
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, stmt := range statements {
+		if stmt != nil {
+			Walk(v, stmt)
+		}
+	}
+}
+
+func walkExpression(v Visitor, exp Expression) {
+	if exp != nil {
+		Walk(v, exp)
+	}
+}
+
+func walkExpressions(v Visitor, expressions []Expression) {
+	for _, exp := range expressions {
+		walkExpression(v, exp)
+	}
+}
+
+func walkIdentifiers(v Visitor, identifiers []*Identifier) {
+	for _, ident := range identifiers {
+		if ident != nil {
+			Walk(v, ident)
+		}
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// 深度优先遍历语法树，对每个节点调用 f(node)，f 返回 true 时继续遍历 node 的子节点。
+// 遍历完子节点后调用 f(nil)
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
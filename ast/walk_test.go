@@ -0,0 +1,171 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 包含所有节点类型的代码
+const allNodes = `import "m.mk" as m;
+import { a } from "n.mk";
+export let f = fn(x, y) { return -x + y; };
+let mac = macro(q) { q };
+if (true) { 1.5 } else { "s" };
+f(1, 2)[0];
+m.v[1:2:3];
+{"k": [x]};
+`
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func nodeName(node ast.Node) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
+
+func TestInspect(t *testing.T) {
+	program := parse(t, allNodes)
+	var visited []string
+	depth, maxDepth := 0, 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		depth++
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		visited = append(visited, nodeName(node))
+		return true
+	})
+	expected := []string{
+		"Program",
+		"ImportStatement", "StringLiteral", "Identifier",
+		"ImportStatement", "Identifier", "StringLiteral",
+		"ExportStatement", "LetStatement", "Identifier",
+		"FunctionLiteral", "Identifier", "Identifier", "BlockStatement",
+		"ReturnStatement", "InfixExpression", "PrefixExpression", "Identifier", "Identifier",
+		"LetStatement", "Identifier", "MacroLiteral", "Identifier", "BlockStatement",
+		"ExpressionStatement", "Identifier",
+		"ExpressionStatement", "IfExpression", "Boolean",
+		"BlockStatement", "ExpressionStatement", "FloatLiteral",
+		"BlockStatement", "ExpressionStatement", "StringLiteral",
+		"ExpressionStatement", "IndexExpression", "CallExpression", "Identifier",
+		"IntegerLiteral", "IntegerLiteral", "IntegerLiteral",
+		"ExpressionStatement", "SliceExpression", "MemberExpression", "Identifier", "Identifier",
+		"IntegerLiteral", "IntegerLiteral", "IntegerLiteral",
+		"ExpressionStatement", "HashLiteral", "StringLiteral", "ArrayLiteral", "Identifier",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("wrong visiting order.\ngot= %v\nwant=%v", visited, expected)
+	}
+	if depth != 0 {
+		t.Errorf("Visit(nil) not called once per node. depth=%d", depth)
+	}
+	if maxDepth != 9 {
+		t.Errorf("wrong max depth. got=%d", maxDepth)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parse(t, `let f = fn(x) { x + 1 }; f(2);`)
+	count := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+		count++
+		_, isFn := node.(*ast.FunctionLiteral)
+		return !isFn
+	})
+	// Program, LetStatement, Identifier, FunctionLiteral,
+	// ExpressionStatement, CallExpression, Identifier, IntegerLiteral
+	if count != 8 {
+		t.Errorf("wrong number of visited nodes. got=%d", count)
+	}
+}
+
+type countingVisitor map[string]int
+
+func (v countingVisitor) Visit(node ast.Node) ast.Visitor {
+	if node != nil {
+		v[nodeName(node)]++
+	}
+	return v
+}
+
+func TestWalk(t *testing.T) {
+	program := parse(t, `let add = fn(a, b) { a + b }; add(1, add(2, 3));`)
+	v := countingVisitor{}
+	ast.Walk(v, program)
+	expected := countingVisitor{
+		"Program":             1,
+		"LetStatement":        1,
+		"FunctionLiteral":     1,
+		"BlockStatement":      1,
+		"ExpressionStatement": 2,
+		"InfixExpression":     1,
+		"CallExpression":      2,
+		"Identifier":          7,
+		"IntegerLiteral":      3,
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("wrong node counts. got=%v, want=%v", v, expected)
+	}
+}
+
+func TestModifyVisitsEveryNode(t *testing.T) {
+	program := parse(t, allNodes)
+	var inspected, modified []string
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			inspected = append(inspected, nodeName(node))
+		}
+		return true
+	})
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		modified = append(modified, nodeName(node))
+		return node
+	})
+	// Modify 先修改子节点，因此顺序不同，但每个节点恰好访问一次
+	count := func(names []string) map[string]int {
+		counts := map[string]int{}
+		for _, name := range names {
+			counts[name]++
+		}
+		return counts
+	}
+	if !reflect.DeepEqual(count(modified), count(inspected)) {
+		t.Errorf("Modify and Inspect visited different nodes.\nmodify= %v\ninspect=%v",
+			count(modified), count(inspected))
+	}
+}
+
+func TestModifyRenamesIdentifiers(t *testing.T) {
+	program := parse(t, `let x = fn(x) { {x: x}[x] }; import { x } from "x.mk"; o.x[x:];`)
+	renamed := ast.Modify(program, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok && ident.Value == "x" {
+			return &ast.Identifier{Token: ident.Token, Value: "y"}
+		}
+		return node
+	})
+	expected := `let y = fn(y) ({y:y}[y]);import { y } from "x.mk";((o.y)[y:])`
+	if renamed.String() != expected {
+		t.Errorf("wrong result.\ngot= %q\nwant=%q", renamed.String(), expected)
+	}
+	if strings.Contains(program.String(), "y") {
+		t.Errorf("original program was modified: %q", program.String())
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/coverage"
+	"monkey/interp"
+	"os"
+)
+
+// monkey cover [--allow-*] [-lcov file] [-html file] file
+// 执行代码文件并统计其中以及 import 的模块中语句和 if 分支的覆盖率，
+// 每个文件的覆盖率输出到标准错误，-lcov 和 -html 指定详细报告写入的位置
+func runCover(args []string) int {
+	flags := flag.NewFlagSet("monkey cover", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey cover [--allow-*] [-lcov file] [-html file] file\n")
+		flags.PrintDefaults()
+	}
+	lcov := flags.String("lcov", "", "write an lcov tracefile to `file`")
+	html := flags.String("html", "", "write an HTML report annotated on the source to `file`")
+	capabilities := capabilityFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	i := interp.New(interp.WithCapabilities(capabilities()))
+	c := coverage.New(i.Evaluator())
+	code := 0
+	if _, err := i.RunFile(flags.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		code = 1
+	}
+	c.WriteSummary(os.Stderr)
+	if *lcov != "" {
+		if err := c.WriteLCOVFile(*lcov); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	if *html != "" {
+		if err := c.WriteHTMLFile(*html); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return code
+}
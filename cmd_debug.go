@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/debugger"
+	"monkey/interp"
+	"os"
+)
+
+// monkey debug [--allow-*] file
+// 在命令行调试器中执行代码文件，程序在第一条语句暂停，输入 help 查看调试命令
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("monkey debug", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey debug [--allow-*] file\n")
+		flags.PrintDefaults()
+	}
+	capabilities := capabilityFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	i := interp.New(interp.WithCapabilities(capabilities()))
+	debugger.New(i.Evaluator(), debugger.NewCLI(os.Stdin, os.Stdout))
+	if _, err := i.RunFile(flags.Arg(0)); err != nil {
+		if err.Error() == debugger.ErrQuit.Error() {
+			return 0
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println("program finished")
+	return 0
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"monkey/formatter"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// monkey fmt [--check] [--write] [path ...]
+// 格式化代码文件，path 为目录时处理其中所有的 .mk 文件，没有 path 时从标准输入读取。
+// 默认把格式化的结果写到标准输出；--write 直接修改文件；
+// --check 只列出格式不正确的文件，存在这样的文件时返回非零的退出码，用于 CI
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("monkey fmt", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey fmt [--check] [--write] [path ...]\n")
+		flags.PrintDefaults()
+	}
+	check := flags.Bool("check", false, "list files whose formatting differs and exit with status 1 if there are any")
+	write := flags.Bool("write", false, "write the result back to the source files")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		formatted, err := formatter.Format(string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "<stdin>: %s\n", err)
+			return 1
+		}
+		if *check {
+			if formatted != string(src) {
+				fmt.Println("<stdin>")
+				return 1
+			}
+			return 0
+		}
+		fmt.Print(formatted)
+		return 0
+	}
+
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	status := 0
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		formatted, err := formatter.Format(string(src))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			status = 1
+			continue
+		}
+		changed := formatted != string(src)
+		switch {
+		case *check:
+			if changed {
+				fmt.Println(path)
+				status = 1
+			}
+		case *write:
+			if changed {
+				if err := os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					status = 1
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	return status
+}
+
+// 展开命令行参数中的目录，返回其中所有的 .mk 文件
+func sourceFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, ".mk") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
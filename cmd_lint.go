@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/parser"
+	"os"
+	"strings"
+)
+
+// monkey lint [path ...]
+// 静态检查代码文件，path 为目录时检查其中所有的 .mk 文件。
+// 每条结果输出为 path:line:column: message (rule)，有任何结果时返回非零的退出码
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("monkey lint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey lint path ...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	status := 0
+	for _, path := range files {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			fmt.Fprintf(os.Stderr, "%s: parser errors:\n\t%s\n", path, strings.Join(p.Errors(), "\n\t"))
+			status = 1
+			continue
+		}
+		for _, d := range lint.Lint(program) {
+			fmt.Printf("%s:%s\n", path, d)
+			status = 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"monkey/lsp"
+	"os"
+)
+
+// monkey lsp
+// 启动 Language Server Protocol 服务器，通过标准输入输出和编辑器通信
+func runLsp(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "usage: monkey lsp\n")
+		return 2
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
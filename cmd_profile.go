@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/interp"
+	"monkey/profiler"
+	"os"
+)
+
+// monkey profile [--allow-*] [-o file] [-top n] file
+// 执行代码文件并统计每个函数的调用次数和耗时，报告输出到标准错误。
+// 指定 -o 时同时写入 pprof 格式的结果，可以用 go tool pprof 查看
+func runProfile(args []string) int {
+	flags := flag.NewFlagSet("monkey profile", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey profile [--allow-*] [-o file] [-top n] file\n")
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "", "write a pprof profile to `file`")
+	top := flags.Int("top", 20, "show only the `n` functions with the highest self time, 0 for all")
+	capabilities := capabilityFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	i := interp.New(interp.WithCapabilities(capabilities()))
+	p := profiler.New(i.Evaluator())
+	p.Start()
+	_, err := i.RunFile(flags.Arg(0))
+	p.Stop()
+
+	code := 0
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		code = 1
+	}
+	p.WriteText(os.Stderr, *top)
+	if *output != "" {
+		if err := p.WriteProfileFile(*output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return code
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey/interp"
+	"monkey/testrunner"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// monkey test [--allow-*] [-run regexp] [-v] [path ...]
+// 运行测试文件中的 test_* 函数，path 为目录时查找其中所有的 _test.mk 文件，默认为当前目录。
+// 有测试失败时返回非零的退出码
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("monkey test", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey test [--allow-*] [-run regexp] [-v] [path ...]\n")
+		flags.PrintDefaults()
+	}
+	run := flags.String("run", "", "run only the tests whose name matches `regexp`")
+	verbose := flags.Bool("v", false, "also report passing tests and their output")
+	capabilities := capabilityFlags(flags)
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := sourceFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// 目录中只运行测试文件，直接指定的文件总是运行
+	tests := []string{}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.mk") || isArg(file, paths) {
+			tests = append(tests, file)
+		}
+	}
+	if len(tests) == 0 {
+		fmt.Println("no test files")
+		return 0
+	}
+
+	runner := &testrunner.Runner{
+		Options: []interp.Option{interp.WithCapabilities(capabilities())},
+		Verbose: *verbose,
+		Out:     os.Stdout,
+	}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		runner.Match = re.MatchString
+	}
+	if !runner.Run(tests) {
+		fmt.Println("FAIL")
+		return 1
+	}
+	fmt.Println("PASS")
+	return 0
+}
+
+func isArg(file string, args []string) bool {
+	for _, arg := range args {
+		if file == arg {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package conformance
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"io/fs"
+	"monkey/interp"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the output of the first backend")
+
+// 执行脚本的后端，所有后端对每个用例都必须产生相同的结果。
+// 新的后端（如字节码虚拟机）加入这个列表后即受到整个测试集的检查
+type backend struct {
+	name string
+	run  func(path string, stdout io.Writer) (object.Object, error)
+}
+
+var backends = []backend{
+	{"evaluator", func(path string, stdout io.Writer) (object.Object, error) {
+		return interp.New(interp.WithStdout(stdout), interp.WithStderr(stdout)).RunFile(path)
+	}},
+}
+
+// 一个用例执行的结果，每个字段对应一种 golden 文件
+type outcome struct {
+	stdout string
+	result string
+	error  string
+}
+
+var goldens = []struct {
+	ext string
+	get func(o *outcome) *string
+}{
+	{".stdout", func(o *outcome) *string { return &o.stdout }},
+	{".result", func(o *outcome) *string { return &o.result }},
+	{".error", func(o *outcome) *string { return &o.error }},
+}
+
+// testdata 下所有的用例，跳过以 _ 开头的目录
+func cases(t *testing.T) []string {
+	t.Helper()
+	paths := []string{}
+	err := filepath.WalkDir("testdata", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && strings.HasPrefix(d.Name(), "_") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".mk") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no conformance cases found")
+	}
+	return paths
+}
+
+func run(t *testing.T, b backend, path string) outcome {
+	t.Helper()
+	var stdout bytes.Buffer
+	result, err := b.run(path, &stdout)
+	o := outcome{stdout: stdout.String()}
+	if err != nil {
+		o.error = err.Error() + "\n"
+	} else if result != nil {
+		o.result = result.Inspect() + "\n"
+	}
+	// 错误信息中的绝对路径与运行的位置有关，替换为相对路径
+	if abs, err := filepath.Abs("testdata"); err == nil {
+		for _, g := range goldens {
+			field := g.get(&o)
+			*field = strings.ReplaceAll(*field, abs, "testdata")
+		}
+	}
+	return o
+}
+
+func readGoldens(t *testing.T, path string) outcome {
+	t.Helper()
+	var o outcome
+	for _, g := range goldens {
+		data, err := os.ReadFile(strings.TrimSuffix(path, ".mk") + g.ext)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		*g.get(&o) = string(data)
+	}
+	return o
+}
+
+// 写入非空的 golden 文件，删除结果为空的
+func writeGoldens(t *testing.T, path string, o outcome) {
+	t.Helper()
+	for _, g := range goldens {
+		golden := strings.TrimSuffix(path, ".mk") + g.ext
+		content := *g.get(&o)
+		if content == "" {
+			if err := os.Remove(golden); err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(golden, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	for _, path := range cases(t) {
+		path := path
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.ToSlash(path), "testdata/"), ".mk")
+		t.Run(name, func(t *testing.T) {
+			if *update {
+				writeGoldens(t, path, run(t, backends[0], path))
+			}
+			expected := readGoldens(t, path)
+			for _, b := range backends {
+				got := run(t, b, path)
+				for _, g := range goldens {
+					if *g.get(&got) != *g.get(&expected) {
+						t.Errorf("%s: wrong %s.\ngot:\n%s\nwant:\n%s", b.name, g.ext[1:], *g.get(&got), *g.get(&expected))
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+// conformance 包是 monkey 语言的一致性测试集，用可执行的例子描述语言的语义。
+//
+// testdata 下每个 .mk 文件是一个用例，同名的 golden 文件记录期望的结果：
+//
+//	name.stdout  脚本的输出
+//	name.result  最后一个表达式的值（Inspect 的结果）
+//	name.error   解析或执行的错误信息
+//
+// 不存在的 golden 文件表示期望的结果为空。名字以 _ 开头的目录不是用例，
+// 用来存放被用例 import 的模块。
+//
+// 每个执行后端（目前只有树遍历的求值器）都必须通过所有用例。修改语义之后用
+//
+//	go test ./conformance -update
+//
+// 重新生成 golden 文件，并检查它们的变化
+package conformance
@@ -0,0 +1,8 @@
+// 整数和浮点数的运算与优先级
+puts(1 + 2 * 3);
+puts((1 + 2) * 3);
+puts(-5 + 10 / 3);
+puts(7 / 2, 7.0 / 2);
+puts(1.5 + 1, 2 * 0.25);
+puts(1 < 2, 2 < 1, 1 == 1.0, 3 != 3);
+10 * 10 - 1
@@ -0,0 +1,6 @@
+// 真值判断：null 和 false 为假，其他值都为真
+puts(!true, !false, !!5);
+puts(true == true, true != false);
+puts(if (0) { "truthy" } else { "falsy" });
+puts(if (if (false) { 1 }) { "truthy" } else { "falsy" });
+!(1 > 2)
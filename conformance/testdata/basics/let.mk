@@ -0,0 +1,7 @@
+// let 绑定和遮蔽
+let a = 5;
+let b = a * 2;
+let a = a + b;
+puts(a, b);
+let c = fn() { let a = 100; a };
+puts(c(), a);
@@ -0,0 +1,11 @@
+// 数组的下标、切片和内置函数
+let a = [1, 2 * 2, "three", [4]];
+puts(a[0], a[1], a[3][0], a[10]);
+puts(len(a), first(a), last(a));
+puts(rest([1, 2, 3]), push([1], 2));
+puts(a[1:3], a[:2], a[2:]);
+puts(map([1, 2, 3], fn(x) { x * x }));
+puts(filter([1, 2, 3, 4], fn(x) { x > 2 }));
+puts(reduce([1, 2, 3, 4], fn(acc, x) { acc + x }, 0));
+puts(sort([3, 1, 2]), [1, 2].map(fn(x) { x + 1 }));
+[1, 2, 3].len()
@@ -0,0 +1,7 @@
+// hash 的字面量、下标和成员访问，按插入顺序输出
+let h = {"name": "monkey", 1: "one", true: "yes", "nested": {"a": [1, 2]}};
+puts(h["name"], h[1], h[true], h["missing"]);
+puts(h.name, h.nested.a[1]);
+puts({"b": 1, "a": 2});
+let key = "dyn" + "amic";
+{key: 1}
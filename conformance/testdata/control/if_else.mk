@@ -0,0 +1,8 @@
+// if 是表达式，没有执行的分支为 null
+let classify = fn(n) {
+  if (n < 0) { "negative" } else { if (n == 0) { "zero" } else { "positive" } }
+};
+puts(classify(-3), classify(0), classify(8));
+puts(if (false) { 1 });
+let x = if (1 > 2) { "yes" } else { "no" };
+x
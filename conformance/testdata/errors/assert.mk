@@ -0,0 +1 @@
+assert_eq([1, 2, 3], [1, 2, 4], "arrays differ")
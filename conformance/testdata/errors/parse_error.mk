@@ -0,0 +1,2 @@
+let = 5;
+let x 10;
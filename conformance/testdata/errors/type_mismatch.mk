@@ -0,0 +1,4 @@
+// 运行时错误中止执行，之前的输出保留
+puts("before");
+let x = 1 + "a";
+puts("after");
@@ -0,0 +1,12 @@
+// 闭包捕获定义时的环境
+let adder = fn(x) { fn(y) { x + y } };
+let add_two = adder(2);
+let add_ten = adder(10);
+puts(add_two(3), add_ten(3));
+
+let counter = fn() {
+  let count = 0;
+  fn() { count + 1 }
+};
+puts(counter()());
+add_two(add_ten(1))
@@ -0,0 +1,10 @@
+// 递归与高阶函数
+let fib = fn(n) {
+  if (n < 2) { return n; }
+  fib(n - 1) + fib(n - 2)
+};
+let twice = fn(f, x) { f(f(x)) };
+puts(fib(15));
+puts(twice(fn(x) { x * 3 }, 2));
+let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } };
+fact(10)
@@ -0,0 +1,13 @@
+// return 从当前函数返回，嵌套的代码块中也一样
+let f = fn(x) {
+  if (x > 10) {
+    if (x > 100) {
+      return "huge";
+    }
+    return "big";
+  }
+  "small"
+};
+puts(f(1), f(50), f(500));
+let g = fn() { return 1; 2 };
+g()
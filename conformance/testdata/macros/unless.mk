@@ -0,0 +1,7 @@
+// 宏在执行前展开，参数不会被求值
+let unless = macro(cond, cons, alt) {
+  quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) })
+};
+unless(10 > 5, puts("not greater"), puts("greater"));
+let q = quote(1 + unquote(2 * 3));
+q
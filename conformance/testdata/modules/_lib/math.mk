@@ -0,0 +1,4 @@
+// 被 import 的模块，只有 export 的绑定可以访问
+let square = fn(x) { x * x };
+export let sum_of_squares = fn(a, b) { square(a) + square(b) };
+export let pi = 3;
@@ -0,0 +1,6 @@
+// import 的路径相对于当前文件
+import { sum_of_squares, pi } from "_lib/math.mk";
+import "_lib/math.mk" as m;
+puts(sum_of_squares(3, 4), pi);
+puts(m.pi, m.sum_of_squares(1, 1));
+m.square
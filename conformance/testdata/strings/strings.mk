@@ -0,0 +1,9 @@
+// 字符串的拼接、比较和方法
+let s = "Hello" + ", " + "World";
+puts(s, len(s));
+puts(upper(s), s.lower());
+puts(split("a,b,c", ","), join(["x", "y"], "-"));
+puts(trim("  pad  "), s.contains("World"), s.starts_with("Hell"));
+puts(s[0], s[7:], s.replace("World", "Monkey"));
+puts(format("%s is %d", "x", 42));
+str([1, "a", {"k": true}])
@@ -0,0 +1,230 @@
+// coverage 包统计 monkey 代码的覆盖率：每个文件中每条语句执行的次数，
+// 以及每个 if 表达式的两个分支各执行的次数。
+// 结果可以输出为 lcov 格式，或在源码上标注覆盖情况的 HTML 页面
+package coverage
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 一条语句
+type Statement struct {
+	Line, Column int
+	Count        int64 // 执行的次数
+}
+
+// 一个 if 表达式的两个分支，没有 else 时条件为假也算作执行了 else 分支
+type Branch struct {
+	Line, Column int
+	HasElse      bool
+
+	reached int64 // if 表达式被求值的次数
+	then    int64
+	els     int64
+}
+
+// 两个分支各执行的次数
+func (b *Branch) Taken() (then, els int64) {
+	if b.HasElse {
+		return b.then, b.els
+	}
+	// 没有 else 的 if，条件求值出错时也会计入 else 分支
+	return b.then, b.reached - b.then
+}
+
+// 一个文件的覆盖情况
+type File struct {
+	Path       string // 文件的绝对路径，不是来自文件的代码为空字符串
+	Statements []*Statement
+	Branches   []*Branch
+}
+
+// 执行过的语句数和语句总数
+func (f *File) StatementCoverage() (covered, total int) {
+	for _, s := range f.Statements {
+		if s.Count > 0 {
+			covered++
+		}
+	}
+	return covered, len(f.Statements)
+}
+
+// 执行过的分支数和分支总数，每个 if 表达式有两个分支
+func (f *File) BranchCoverage() (covered, total int) {
+	for _, b := range f.Branches {
+		then, els := b.Taken()
+		if then > 0 {
+			covered++
+		}
+		if els > 0 {
+			covered++
+		}
+	}
+	return covered, 2 * len(f.Branches)
+}
+
+// if 表达式中的代码块属于哪个分支
+type block struct {
+	branch      *Branch
+	alternative bool
+}
+
+type Coverage struct {
+	evaluator.NopHooks
+
+	files      map[string]*File
+	order      []*File // 按加载的顺序
+	statements map[ast.Node]*Statement
+	ifs        map[*ast.IfExpression]*Branch
+	blocks     map[*ast.BlockStatement]block
+}
+
+// 创建覆盖率统计并设置为 e 的钩子
+func New(e *evaluator.Evaluator) *Coverage {
+	c := &Coverage{
+		files:      map[string]*File{},
+		statements: map[ast.Node]*Statement{},
+		ifs:        map[*ast.IfExpression]*Branch{},
+		blocks:     map[*ast.BlockStatement]block{},
+	}
+	e.SetHooks(c)
+	return c
+}
+
+// 加载过的文件，按加载的顺序
+func (c *Coverage) Files() []*File {
+	return c.order
+}
+
+// evaluator.Hooks
+
+func (c *Coverage) LoadProgram(program *ast.Program, path string) {
+	f, ok := c.files[path]
+	if !ok {
+		f = &File{Path: path}
+		c.files[path] = f
+		c.order = append(c.order, f)
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement, *ast.ReturnStatement, *ast.ExpressionStatement, *ast.ImportStatement:
+			line, column := ast.Pos(node)
+			s := &Statement{Line: line, Column: column}
+			c.statements[node] = s
+			f.Statements = append(f.Statements, s)
+		case *ast.IfExpression:
+			b := &Branch{Line: node.Token.Line, Column: node.Token.Column, HasElse: node.Alternative != nil}
+			c.ifs[node] = b
+			if node.Consequence != nil {
+				c.blocks[node.Consequence] = block{branch: b}
+			}
+			if node.Alternative != nil {
+				c.blocks[node.Alternative] = block{branch: b, alternative: true}
+			}
+			f.Branches = append(f.Branches, b)
+		}
+		return true
+	})
+	sort.SliceStable(f.Statements, func(i, j int) bool {
+		return before(f.Statements[i].Line, f.Statements[i].Column, f.Statements[j].Line, f.Statements[j].Column)
+	})
+	sort.SliceStable(f.Branches, func(i, j int) bool {
+		return before(f.Branches[i].Line, f.Branches[i].Column, f.Branches[j].Line, f.Branches[j].Column)
+	})
+}
+
+func before(line1, column1, line2, column2 int) bool {
+	if line1 != line2 {
+		return line1 < line2
+	}
+	return column1 < column2
+}
+
+func (c *Coverage) BeforeEval(node ast.Node, env *object.Environment) error {
+	switch node := node.(type) {
+	case *ast.IfExpression:
+		if b, ok := c.ifs[node]; ok {
+			b.reached++
+		}
+	case *ast.BlockStatement:
+		if b, ok := c.blocks[node]; ok {
+			if b.alternative {
+				b.branch.els++
+			} else {
+				b.branch.then++
+			}
+		}
+	default:
+		if s, ok := c.statements[node]; ok {
+			s.Count++
+		}
+	}
+	return nil
+}
+
+// 每行的覆盖情况
+type line struct {
+	count      int64 // 这一行的语句执行次数的最大值
+	statements int
+	covered    int
+}
+
+func lines(f *File) map[int]*line {
+	result := map[int]*line{}
+	for _, s := range f.Statements {
+		l, ok := result[s.Line]
+		if !ok {
+			l = &line{}
+			result[s.Line] = l
+		}
+		l.statements++
+		if s.Count > 0 {
+			l.covered++
+		}
+		if s.Count > l.count {
+			l.count = s.Count
+		}
+	}
+	return result
+}
+
+// 输出每个文件的语句和分支覆盖率
+func (c *Coverage) WriteSummary(w io.Writer) error {
+	var out strings.Builder
+	for _, f := range c.order {
+		covered, total := f.StatementCoverage()
+		fmt.Fprintf(&out, "%s: statements %s", displayPath(f.Path), percent(covered, total))
+		covered, total = f.BranchCoverage()
+		fmt.Fprintf(&out, ", branches %s\n", percent(covered, total))
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+func percent(covered, total int) string {
+	if total == 0 {
+		return "0/0"
+	}
+	return fmt.Sprintf("%d/%d (%.1f%%)", covered, total, float64(covered)/float64(total)*100)
+}
+
+// 文件路径尽量显示为相对于当前目录的路径
+func displayPath(path string) string {
+	if path == "" {
+		return "<input>"
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
@@ -0,0 +1,152 @@
+package coverage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/interp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const libSource = `export let abs = fn(x) {
+  if (x < 0) { return -x; }
+  x
+};
+export let sign = fn(x) {
+  if (x < 0) { -1 } else { if (x == 0) { 0 } else { 1 } }
+};
+`
+
+const mainSource = `import { abs, sign } from "lib.mk";
+abs(-3);
+sign(5);
+sign(7);
+`
+
+func run(t *testing.T) (*Coverage, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range map[string]string{"lib.mk": libSource, "main.mk": mainSource} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	i := interp.New(interp.WithStdout(io.Discard))
+	c := New(i.Evaluator())
+	if _, err := i.RunFile(filepath.Join(dir, "main.mk")); err != nil {
+		t.Fatal(err)
+	}
+	return c, dir
+}
+
+func TestCoverage(t *testing.T) {
+	c, dir := run(t)
+	files := c.Files()
+	if len(files) != 2 || files[0].Path != filepath.Join(dir, "main.mk") || files[1].Path != filepath.Join(dir, "lib.mk") {
+		t.Fatalf("wrong files %v", files)
+	}
+
+	statements := []string{}
+	for _, s := range files[1].Statements {
+		statements = append(statements, fmt.Sprintf("%d:%d=%d", s.Line, s.Column, s.Count))
+	}
+	expected := "1:8=1 2:3=1 2:16=1 3:3=0 5:8=1 6:3=2 6:16=0 6:28=2 6:42=0 6:53=2"
+	if got := strings.Join(statements, " "); got != expected {
+		t.Errorf("wrong statement counts.\ngot:  %s\nwant: %s", got, expected)
+	}
+
+	branches := []string{}
+	for _, b := range files[1].Branches {
+		then, els := b.Taken()
+		branches = append(branches, fmt.Sprintf("%d:%d=%d/%d", b.Line, b.Column, then, els))
+	}
+	// 第一个 if 没有 else，条件为真时不执行 else 分支
+	expected = "2:3=1/0 6:3=0/2 6:28=0/2"
+	if got := strings.Join(branches, " "); got != expected {
+		t.Errorf("wrong branch counts.\ngot:  %s\nwant: %s", got, expected)
+	}
+
+	if covered, total := files[1].StatementCoverage(); covered != 7 || total != 10 {
+		t.Errorf("wrong statement coverage %d/%d", covered, total)
+	}
+	if covered, total := files[1].BranchCoverage(); covered != 3 || total != 6 {
+		t.Errorf("wrong branch coverage %d/%d", covered, total)
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	c, dir := run(t)
+	var out bytes.Buffer
+	if err := c.WriteLCOV(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := `TN:
+SF:` + filepath.Join(dir, "main.mk") + `
+BRF:0
+BRH:0
+DA:1,1
+DA:2,1
+DA:3,1
+DA:4,1
+LF:4
+LH:4
+end_of_record
+TN:
+SF:` + filepath.Join(dir, "lib.mk") + `
+BRDA:2,0,0,1
+BRDA:2,0,1,0
+BRDA:6,1,0,0
+BRDA:6,1,1,2
+BRDA:6,2,0,0
+BRDA:6,2,1,2
+BRF:6
+BRH:3
+DA:1,1
+DA:2,1
+DA:3,0
+DA:5,1
+DA:6,2
+LF:5
+LH:4
+end_of_record
+`
+	if out.String() != expected {
+		t.Errorf("wrong lcov output.\ngot:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	c, _ := run(t)
+	var out bytes.Buffer
+	if err := c.WriteHTML(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`lib.mk: statements 7/10 (70.0%), branches 3/6 (50.0%)</h2>`,
+		`<tr class="covered"><td class="number">1</td><td class="count">1</td><td class="text">import { abs, sign } from &#34;lib.mk&#34;;</td></tr>`,
+		`<tr class="partial" title="if at column 3: else branch not taken"><td class="number">2</td><td class="count">1</td><td class="text">  if (x &lt; 0) { return -x; }</td></tr>`,
+		`<tr class="uncovered"><td class="number">3</td><td class="count">0</td><td class="text">  x</td></tr>`,
+		`<tr class=""><td class="number">4</td><td class="count"></td><td class="text">};</td></tr>`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("HTML report does not contain %q.\nreport:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	i := interp.New(interp.WithStdout(io.Discard))
+	c := New(i.Evaluator())
+	if _, err := i.Run("let f = fn(x) { if (x) { 1 } };\nf(false);"); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	c.WriteSummary(&out)
+	expected := "<input>: statements 3/4 (75.0%), branches 1/2 (50.0%)\n"
+	if out.String() != expected {
+		t.Errorf("wrong summary %q, want %q", out.String(), expected)
+	}
+}
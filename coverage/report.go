@@ -0,0 +1,175 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 按 lcov 的 tracefile 格式输出，genhtml 等工具可以读取。
+// 语句按行汇总为 DA 记录，每个 if 表达式对应两条 BRDA 记录
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	var out strings.Builder
+	for _, f := range c.order {
+		if f.Path == "" {
+			continue
+		}
+		fmt.Fprintf(&out, "TN:\nSF:%s\n", f.Path)
+		for i, b := range f.Branches {
+			then, els := b.Taken()
+			for j, count := range []int64{then, els} {
+				taken := "-"
+				if b.reached > 0 {
+					taken = fmt.Sprint(count)
+				}
+				fmt.Fprintf(&out, "BRDA:%d,%d,%d,%s\n", b.Line, i, j, taken)
+			}
+		}
+		covered, total := f.BranchCoverage()
+		fmt.Fprintf(&out, "BRF:%d\nBRH:%d\n", total, covered)
+
+		byLine := lines(f)
+		numbers := make([]int, 0, len(byLine))
+		hit := 0
+		for n, l := range byLine {
+			numbers = append(numbers, n)
+			if l.count > 0 {
+				hit++
+			}
+		}
+		sort.Ints(numbers)
+		for _, n := range numbers {
+			fmt.Fprintf(&out, "DA:%d,%d\n", n, byLine[n].count)
+		}
+		fmt.Fprintf(&out, "LF:%d\nLH:%d\nend_of_record\n", len(numbers), hit)
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// 把 lcov 格式的结果写入文件
+func (c *Coverage) WriteLCOVFile(path string) error {
+	return writeFile(path, c.WriteLCOV)
+}
+
+// 把 HTML 格式的结果写入文件
+func (c *Coverage) WriteHTMLFile(path string) error {
+	return writeFile(path, c.WriteHTML)
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type htmlFile struct {
+	Name       string
+	Statements string
+	Branches   string
+	Lines      []htmlLine
+	Missing    bool // 读取不到源码
+}
+
+type htmlLine struct {
+	Number int
+	Count  string
+	Class  string // covered、partial 或 uncovered，没有语句的行为空
+	Title  string // 没有执行的分支
+	Text   string
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>monkey coverage</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+table { border-collapse: collapse; font-family: monospace; font-size: 0.9em; }
+td { padding: 0 0.5em; white-space: pre; vertical-align: top; }
+td.number, td.count { text-align: right; color: #888; }
+tr.covered td.text { background: #dfd; }
+tr.partial td.text { background: #ffd; }
+tr.uncovered td.text { background: #fdd; }
+</style>
+</head>
+<body>
+{{- range .}}
+<h2>{{.Name}}: statements {{.Statements}}, branches {{.Branches}}</h2>
+{{- if .Missing}}
+<p>source not available</p>
+{{- else}}
+<table>
+{{- range .Lines}}
+<tr class="{{.Class}}"{{if .Title}} title="{{.Title}}"{{end}}><td class="number">{{.Number}}</td><td class="count">{{.Count}}</td><td class="text">{{.Text}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// 输出在源码上标注覆盖情况的 HTML 页面。
+// 每行显示语句执行的次数，全部执行的行为绿色，部分执行或有分支没有执行的行为黄色，没有执行的行为红色
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	files := []htmlFile{}
+	for _, f := range c.order {
+		covered, total := f.StatementCoverage()
+		hf := htmlFile{Name: displayPath(f.Path), Statements: percent(covered, total)}
+		covered, total = f.BranchCoverage()
+		hf.Branches = percent(covered, total)
+
+		data, err := os.ReadFile(f.Path)
+		if f.Path == "" || err != nil {
+			hf.Missing = true
+			files = append(files, hf)
+			continue
+		}
+		byLine := lines(f)
+		missed := map[int][]string{}
+		for _, b := range f.Branches {
+			then, els := b.Taken()
+			if then == 0 {
+				missed[b.Line] = append(missed[b.Line], fmt.Sprintf("if at column %d: then branch not taken", b.Column))
+			}
+			if els == 0 {
+				missed[b.Line] = append(missed[b.Line], fmt.Sprintf("if at column %d: else branch not taken", b.Column))
+			}
+		}
+		for i, text := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			hl := htmlLine{Number: i + 1, Text: text}
+			if l, ok := byLine[i+1]; ok {
+				hl.Count = fmt.Sprint(l.count)
+				switch {
+				case l.covered == 0:
+					hl.Class = "uncovered"
+				case l.covered < l.statements:
+					hl.Class = "partial"
+				default:
+					hl.Class = "covered"
+				}
+			}
+			if m, ok := missed[i+1]; ok {
+				hl.Title = strings.Join(m, "\n")
+				if hl.Class == "covered" || hl.Class == "" {
+					hl.Class = "partial"
+				}
+			}
+			hf.Lines = append(hf.Lines, hl)
+		}
+		files = append(files, hf)
+	}
+	return htmlTemplate.Execute(w, files)
+}
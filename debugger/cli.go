@@ -0,0 +1,252 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const cliHelp = `commands:
+  c, continue          run until the next breakpoint
+  s, step              step to the next statement, entering function calls
+  n, next              step to the next statement in the current function
+  o, out               run until the current function returns
+  b, break [file:]line set a breakpoint, without arguments list breakpoints
+  d, delete [file:]line
+                       delete a breakpoint
+  p, print expr        evaluate an expression in the selected frame
+  bt, backtrace        show the call stack
+  f, frame n           select frame n of the call stack
+  env                  show the environment chain of the selected frame
+  l, list              show the source around the current line
+  q, quit              stop the program
+  h, help              show this help
+an empty line repeats the previous command`
+
+// 命令行前端，从 in 读取命令，把结果写到 out
+type CLI struct {
+	in      *bufio.Scanner
+	out     io.Writer
+	frame   int    // 选中的调用栈层，print、env 和 list 使用
+	last    string // 上一条命令，输入空行时重复执行
+	sources map[string][]string
+}
+
+func NewCLI(in io.Reader, out io.Writer) *CLI {
+	return &CLI{in: bufio.NewScanner(in), out: out, sources: map[string][]string{}}
+}
+
+func (c *CLI) Stopped(d *Debugger, reason string) Action {
+	c.frame = 0
+	frame := d.Frames()[0]
+	fmt.Fprintf(c.out, "stopped at %s:%d:%d in %s (%s)\n", displayPath(frame.File), frame.Line, frame.Column, frame.Name, reason)
+	c.printLines(frame.File, frame.Line, 0)
+
+	for {
+		fmt.Fprint(c.out, "(mdb) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return Quit
+		}
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.last
+		}
+		c.last = line
+		cmd, arg := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		switch cmd {
+		case "":
+		case "c", "continue":
+			return Continue
+		case "s", "step":
+			return StepIn
+		case "n", "next":
+			return StepOver
+		case "o", "out", "finish":
+			return StepOut
+		case "q", "quit":
+			return Quit
+		case "b", "break":
+			c.breakCommand(d, arg)
+		case "d", "delete":
+			c.deleteCommand(d, arg)
+		case "p", "print":
+			c.printCommand(d, arg)
+		case "bt", "backtrace":
+			c.backtrace(d)
+		case "f", "frame":
+			c.frameCommand(d, arg)
+		case "env":
+			c.envCommand(d)
+		case "l", "list":
+			frame := d.Frames()[c.frame]
+			c.printLines(frame.File, frame.Line, 5)
+		case "h", "help":
+			fmt.Fprintln(c.out, cliHelp)
+		default:
+			fmt.Fprintf(c.out, "unknown command %q, type help for a list of commands\n", cmd)
+		}
+	}
+}
+
+// 解析 [file:]line 形式的位置
+func parseLocation(arg string) (string, int, error) {
+	file, lineText := "", arg
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		file, lineText = arg[:i], arg[i+1:]
+	}
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line <= 0 {
+		return "", 0, fmt.Errorf("invalid location %q, want [file:]line", arg)
+	}
+	return file, line, nil
+}
+
+func (c *CLI) breakCommand(d *Debugger, arg string) {
+	if arg == "" {
+		breakpoints := d.Breakpoints()
+		if len(breakpoints) == 0 {
+			fmt.Fprintln(c.out, "no breakpoints")
+		}
+		for _, b := range breakpoints {
+			fmt.Fprintf(c.out, "%s:%d\n", displayPath(b.File), b.Line)
+		}
+		return
+	}
+	file, line, err := parseLocation(arg)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+	b := d.SetBreakpoint(file, line)
+	fmt.Fprintf(c.out, "breakpoint set at %s:%d\n", displayPath(b.File), b.Line)
+}
+
+func (c *CLI) deleteCommand(d *Debugger, arg string) {
+	file, line, err := parseLocation(arg)
+	if err != nil {
+		fmt.Fprintln(c.out, err)
+		return
+	}
+	if !d.ClearBreakpoint(file, line) {
+		fmt.Fprintf(c.out, "no breakpoint at %s\n", arg)
+		return
+	}
+	fmt.Fprintf(c.out, "breakpoint at %s deleted\n", arg)
+}
+
+func (c *CLI) printCommand(d *Debugger, arg string) {
+	if arg == "" {
+		fmt.Fprintln(c.out, "usage: print expr")
+		return
+	}
+	result, err := d.Evaluate(c.frame, arg)
+	if err != nil {
+		fmt.Fprintf(c.out, "error: %s\n", err)
+		return
+	}
+	fmt.Fprintln(c.out, result.Inspect())
+}
+
+func (c *CLI) backtrace(d *Debugger) {
+	for i, frame := range d.Frames() {
+		marker := " "
+		if i == c.frame {
+			marker = "*"
+		}
+		fmt.Fprintf(c.out, "%s #%d %s at %s:%d:%d\n", marker, i, frame.Name, displayPath(frame.File), frame.Line, frame.Column)
+	}
+}
+
+func (c *CLI) frameCommand(d *Debugger, arg string) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 || n >= len(d.Frames()) {
+		fmt.Fprintf(c.out, "invalid frame %q, there are %d frames\n", arg, len(d.Frames()))
+		return
+	}
+	c.frame = n
+	frame := d.Frames()[n]
+	fmt.Fprintf(c.out, "#%d %s at %s:%d:%d\n", n, frame.Name, displayPath(frame.File), frame.Line, frame.Column)
+	c.printLines(frame.File, frame.Line, 0)
+}
+
+// 从内到外显示环境链中每一层的绑定
+func (c *CLI) envCommand(d *Debugger) {
+	env := d.Frames()[c.frame].Env
+	for level := 0; env != nil; level++ {
+		name := "local"
+		switch {
+		case env.Outer() == nil:
+			name = "global"
+		case level > 0:
+			name = "enclosing"
+		}
+		fmt.Fprintf(c.out, "%s:\n", name)
+		for _, binding := range env.Names() {
+			value, _ := env.Get(binding)
+			fmt.Fprintf(c.out, "  %s = %s\n", binding, summary(value))
+		}
+		env = env.Outer()
+	}
+}
+
+// 显示源码中 line 前后 radius 行，line 用 => 标记
+func (c *CLI) printLines(file string, line, radius int) {
+	lines, ok := c.sources[file]
+	if !ok {
+		lines = readLines(file)
+		c.sources[file] = lines
+	}
+	for n := line - radius; n <= line+radius; n++ {
+		if n < 1 || n > len(lines) {
+			continue
+		}
+		marker := "  "
+		if n == line {
+			marker = "=>"
+		}
+		fmt.Fprintf(c.out, "%s %4d  %s\n", marker, n, lines[n-1])
+	}
+}
+
+// 值的简短表示，函数只显示参数，过长的值截断
+func summary(obj object.Object) string {
+	var s string
+	switch obj := obj.(type) {
+	case *object.Function:
+		params := make([]string, len(obj.Parameters))
+		for i, p := range obj.Parameters {
+			params[i] = p.Value
+		}
+		s = "fn(" + strings.Join(params, ", ") + ")"
+	case *object.String:
+		s = strconv.Quote(obj.Value)
+	default:
+		s = obj.Inspect()
+	}
+	if runes := []rune(s); len(runes) > 80 {
+		s = string(runes[:77]) + "..."
+	}
+	return s
+}
+
+// 文件路径尽量显示为相对于当前目录的路径
+func displayPath(path string) string {
+	if path == "" {
+		return "<input>"
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
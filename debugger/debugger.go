@@ -0,0 +1,315 @@
+// debugger 包实现 monkey 的调试器：行断点、单步执行（step in / over / out）、
+// 查看调用栈和环境链，以及在暂停处求值表达式。
+// 调试器通过 evaluator.Hooks 接入求值过程，暂停时由 Frontend 决定如何继续；
+// 目前提供命令行前端 CLI，Debug Adapter Protocol 等前端也可以基于 Frontend 实现
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 用户选择退出时中止执行的错误
+var ErrQuit = errors.New("program stopped by debugger")
+
+// 暂停后如何继续执行
+type Action int
+
+const (
+	Continue Action = iota // 运行到下一个断点
+	StepIn                 // 停在下一条语句，包括被调用的函数里的语句
+	StepOver               // 停在当前函数的下一条语句，不进入被调用的函数
+	StepOut                // 停在调用当前函数的函数的下一条语句
+	Quit                   // 中止执行
+)
+
+// 暂停的原因
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+)
+
+// 调试器的前端，执行暂停时被调用，返回如何继续执行。
+// 在 Stopped 中可以调用 Debugger 的方法查看调用栈、设置断点等
+type Frontend interface {
+	Stopped(d *Debugger, reason string) Action
+}
+
+// 调用栈中的一层
+type Frame struct {
+	Name     string              // 函数名，顶层代码为 "<main>"，匿名函数为 "fn@行:列"
+	Function *object.Function    // 被调用的函数，顶层代码为 nil
+	Env      *object.Environment // 当前的环境
+	File     string              // 当前语句所在的文件，不是来自文件的代码为空字符串
+	Line     int                 // 当前语句的位置
+	Column   int
+
+	lastLine int // 上一条语句所在的行，同一行的多条语句只在第一条触发断点
+}
+
+// 断点的位置
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+func (b Breakpoint) String() string {
+	return fmt.Sprintf("%s:%d", b.File, b.Line)
+}
+
+type Debugger struct {
+	eval     *evaluator.Evaluator
+	frontend Frontend
+
+	files       map[ast.Node]string // 语句所在的文件
+	main        string              // 第一个加载的文件，断点没有指定文件时使用
+	breakpoints map[Breakpoint]bool
+
+	frames    []*Frame // 调用栈，最后一个是当前的函数
+	action    Action
+	stepDepth int  // 开始单步时调用栈的深度
+	started   bool // 是否已经在第一条语句暂停过
+	quit      bool
+	busy      bool // 正在求值前端请求的表达式，此时不触发断点
+}
+
+// 创建调试器并设置为 e 的钩子。程序开始执行时在第一条语句暂停
+func New(e *evaluator.Evaluator, frontend Frontend) *Debugger {
+	d := &Debugger{
+		eval:        e,
+		frontend:    frontend,
+		files:       map[ast.Node]string{},
+		breakpoints: map[Breakpoint]bool{},
+		frames:      []*Frame{{Name: "<main>"}},
+		action:      StepIn,
+	}
+	e.SetHooks(d)
+	return d
+}
+
+// 设置断点。file 为空时使用主程序文件；相对路径先按已加载文件的路径后缀匹配，否则相对于当前目录
+func (d *Debugger) SetBreakpoint(file string, line int) Breakpoint {
+	b := Breakpoint{File: d.resolveFile(file), Line: line}
+	d.breakpoints[b] = true
+	return b
+}
+
+// 删除断点，断点不存在时返回 false
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	b := Breakpoint{File: d.resolveFile(file), Line: line}
+	if !d.breakpoints[b] {
+		return false
+	}
+	delete(d.breakpoints, b)
+	return true
+}
+
+// 所有断点，按文件和行排序
+func (d *Debugger) Breakpoints() []Breakpoint {
+	result := make([]Breakpoint, 0, len(d.breakpoints))
+	for b := range d.breakpoints {
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].File != result[j].File {
+			return result[i].File < result[j].File
+		}
+		return result[i].Line < result[j].Line
+	})
+	return result
+}
+
+// 把断点中的文件名转换为加载时使用的绝对路径
+func (d *Debugger) resolveFile(file string) string {
+	if file == "" {
+		return d.main
+	}
+	if filepath.IsAbs(file) {
+		return filepath.Clean(file)
+	}
+	for _, loaded := range d.loadedFiles() {
+		if loaded == file || strings.HasSuffix(loaded, string(filepath.Separator)+filepath.Clean(file)) {
+			return loaded
+		}
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
+
+func (d *Debugger) loadedFiles() []string {
+	seen := map[string]bool{}
+	files := []string{}
+	for _, file := range d.files {
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// 主程序文件的路径
+func (d *Debugger) MainFile() string {
+	return d.main
+}
+
+// 调用栈，第一个是当前的函数
+func (d *Debugger) Frames() []*Frame {
+	frames := make([]*Frame, len(d.frames))
+	for i, frame := range d.frames {
+		frames[len(d.frames)-1-i] = frame
+	}
+	return frames
+}
+
+// 在调用栈的第 frame 层（0 表示当前函数）的环境中求值表达式。
+// 求值时不会触发断点；表达式中的 let 会修改该层的环境
+func (d *Debugger) Evaluate(frame int, source string) (object.Object, error) {
+	frames := d.Frames()
+	if frame < 0 || frame >= len(frames) || frames[frame].Env == nil {
+		return nil, fmt.Errorf("no frame %d", frame)
+	}
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parser errors: %s", strings.Join(p.Errors(), "; "))
+	}
+	d.busy = true
+	defer func() { d.busy = false }()
+	result := d.eval.Eval(program, frames[frame].Env)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+	if result == nil {
+		result = evaluator.NULL
+	}
+	return result, nil
+}
+
+// evaluator.Hooks
+
+func (d *Debugger) LoadProgram(program *ast.Program, path string) {
+	if d.main == "" {
+		d.main = path
+		// 加载主程序之前设置的没有指定文件的断点
+		for b := range d.breakpoints {
+			if b.File == "" {
+				delete(d.breakpoints, b)
+				d.breakpoints[Breakpoint{File: path, Line: b.Line}] = true
+			}
+		}
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			d.files[node] = path
+		}
+		return true
+	})
+}
+
+func (d *Debugger) BeforeEval(node ast.Node, env *object.Environment) error {
+	if d.busy {
+		return nil
+	}
+	if d.quit {
+		return ErrQuit
+	}
+	if !isStopPoint(node) {
+		return nil
+	}
+	frame := d.frames[len(d.frames)-1]
+	frame.Env = env
+	frame.File = d.files[node]
+	frame.Line, frame.Column = ast.Pos(node)
+	newLine := frame.Line != frame.lastLine
+	frame.lastLine = frame.Line
+
+	reason := ""
+	depth := len(d.frames)
+	switch {
+	case !d.started:
+		reason = ReasonEntry
+	case d.action == StepIn,
+		d.action == StepOver && depth <= d.stepDepth,
+		d.action == StepOut && depth < d.stepDepth:
+		reason = ReasonStep
+	case newLine && d.breakpoints[Breakpoint{frame.File, frame.Line}]:
+		reason = ReasonBreakpoint
+	default:
+		return nil
+	}
+	d.started = true
+
+	action := d.frontend.Stopped(d, reason)
+	if action == Quit {
+		d.quit = true
+		return ErrQuit
+	}
+	d.action = action
+	d.stepDepth = len(d.frames)
+	return nil
+}
+
+func (d *Debugger) EnterFunction(fn *object.Function, call *ast.CallExpression, env *object.Environment) {
+	if d.busy {
+		return
+	}
+	d.frames = append(d.frames, &Frame{Name: functionName(fn, call), Function: fn, Env: env})
+}
+
+func (d *Debugger) ExitFunction(fn *object.Function, result object.Object) {
+	if d.busy {
+		return
+	}
+	if len(d.frames) > 1 {
+		d.frames = d.frames[:len(d.frames)-1]
+	}
+}
+
+// 只在语句上暂停。代码块本身不是暂停的位置，export 在其中的 let 语句上暂停
+func isStopPoint(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.LetStatement, *ast.ReturnStatement, *ast.ExpressionStatement, *ast.ImportStatement:
+		return true
+	}
+	return false
+}
+
+// 函数的名字取调用处的写法，如 add、math.add；被内置函数回调时用函数体的位置表示
+func functionName(fn *object.Function, call *ast.CallExpression) string {
+	if call != nil {
+		switch callee := call.Function.(type) {
+		case *ast.Identifier, *ast.MemberExpression:
+			return callee.String()
+		}
+	}
+	if fn.Body != nil {
+		return fmt.Sprintf("fn@%d:%d", fn.Body.Token.Line, fn.Body.Token.Column)
+	}
+	return "fn"
+}
+
+// 读取文件的内容，用于显示源码
+func readLines(path string) []string {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(string(data), "\n")
+}
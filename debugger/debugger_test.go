@@ -0,0 +1,314 @@
+package debugger
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/interp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 按顺序返回预先设置的动作，并记录每次暂停的位置
+type scriptedFrontend struct {
+	actions []Action
+	stops   []string
+	onStop  func(d *Debugger)
+}
+
+func (f *scriptedFrontend) Stopped(d *Debugger, reason string) Action {
+	frame := d.Frames()[0]
+	f.stops = append(f.stops, fmt.Sprintf("%s %s:%d %s", reason, filepath.Base(frame.File), frame.Line, frame.Name))
+	if f.onStop != nil {
+		f.onStop(d)
+	}
+	if len(f.actions) == 0 {
+		return Continue
+	}
+	action := f.actions[0]
+	f.actions = f.actions[1:]
+	return action
+}
+
+const testScript = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let twice = fn(x) {
+  let y = add(x, x);
+  y
+};
+let r = twice(5);
+puts(r);
+`
+
+func writeScript(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runDebugged(t *testing.T, path string, f *scriptedFrontend, setup func(d *Debugger)) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	i := interp.New(interp.WithStdout(&out))
+	d := New(i.Evaluator(), f)
+	if setup != nil {
+		setup(d)
+	}
+	_, err := i.RunFile(path)
+	return out.String(), err
+}
+
+func TestStepping(t *testing.T) {
+	path := writeScript(t, "main.mk", testScript)
+	tests := []struct {
+		name     string
+		actions  []Action
+		expected []string
+	}{
+		{"continue", []Action{Continue}, []string{"entry main.mk:1 <main>"}},
+		{"step in", []Action{StepIn, StepIn, StepIn, StepIn, StepIn, StepIn, StepIn, StepIn, Continue}, []string{
+			"entry main.mk:1 <main>",
+			"step main.mk:5 <main>",
+			"step main.mk:9 <main>",
+			"step main.mk:6 twice",
+			"step main.mk:2 add",
+			"step main.mk:3 add",
+			"step main.mk:7 twice",
+			"step main.mk:10 <main>",
+		}},
+		{"step over", []Action{StepOver, StepOver, StepOver, StepOver}, []string{
+			"entry main.mk:1 <main>",
+			"step main.mk:5 <main>",
+			"step main.mk:9 <main>",
+			"step main.mk:10 <main>",
+		}},
+		{"step out", []Action{StepOver, StepOver, StepIn, StepIn, StepOut, StepOut}, []string{
+			"entry main.mk:1 <main>",
+			"step main.mk:5 <main>",
+			"step main.mk:9 <main>",
+			"step main.mk:6 twice",
+			"step main.mk:2 add",
+			"step main.mk:7 twice",
+			"step main.mk:10 <main>",
+		}},
+	}
+	for _, tt := range tests {
+		f := &scriptedFrontend{actions: tt.actions}
+		out, err := runDebugged(t, path, f, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if out != "10\n" {
+			t.Errorf("%s: wrong output %q", tt.name, out)
+		}
+		if strings.Join(f.stops, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: wrong stops.\ngot:\n%s\nwant:\n%s", tt.name, strings.Join(f.stops, "\n"), strings.Join(tt.expected, "\n"))
+		}
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	path := writeScript(t, "main.mk", testScript+"twice(1);\n")
+	expected := []string{
+		"entry main.mk:1 <main>",
+		"breakpoint main.mk:2 add",
+		"step main.mk:3 add",
+		"breakpoint main.mk:10 <main>",
+		"breakpoint main.mk:2 add",
+	}
+	f := &scriptedFrontend{actions: []Action{Continue, StepOver, Continue, Continue}}
+	if _, err := runDebugged(t, path, f, func(d *Debugger) {
+		d.SetBreakpoint(path, 2)
+		d.SetBreakpoint(path, 10)
+		d.SetBreakpoint(path, 4)
+		if !d.ClearBreakpoint(path, 4) || d.ClearBreakpoint(path, 4) {
+			t.Errorf("ClearBreakpoint returned wrong result")
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(f.stops, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong stops.\ngot:\n%s\nwant:\n%s", strings.Join(f.stops, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestBreakpointInModule(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.mk")
+	main := filepath.Join(dir, "main.mk")
+	os.WriteFile(lib, []byte("export let inc = fn(x) {\n  x + 1\n};\n"), 0o644)
+	os.WriteFile(main, []byte("import { inc } from \"lib.mk\";\nputs(inc(1));\n"), 0o644)
+
+	f := &scriptedFrontend{}
+	f.onStop = func(d *Debugger) {
+		if len(f.stops) == 1 {
+			// 模块还没有加载，按文件名设置断点
+			if b := d.SetBreakpoint("lib.mk", 2); b.File != filepath.Join(dir, "lib.mk") {
+				t.Errorf("breakpoint resolved to %s", b.File)
+			}
+		}
+	}
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+	if _, err := runDebugged(t, main, f, nil); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"entry main.mk:1 <main>", "breakpoint lib.mk:2 inc"}
+	if strings.Join(f.stops, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong stops.\ngot:\n%s\nwant:\n%s", strings.Join(f.stops, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestInspect(t *testing.T) {
+	path := writeScript(t, "main.mk", testScript)
+	f := &scriptedFrontend{}
+	checked := false
+	f.onStop = func(d *Debugger) {
+		if len(f.stops) != 2 {
+			return
+		}
+		checked = true
+		frames := d.Frames()
+		names := []string{}
+		for _, frame := range frames {
+			names = append(names, fmt.Sprintf("%s:%d", frame.Name, frame.Line))
+		}
+		if got := strings.Join(names, " "); got != "add:2 twice:6 <main>:9" {
+			t.Errorf("wrong frames %s", got)
+		}
+		if got := strings.Join(frames[0].Env.Names(), ","); got != "a,b" {
+			t.Errorf("wrong local names %s", got)
+		}
+		if frames[0].Env.Outer() != frames[2].Env {
+			t.Errorf("closure environment of add is not the global environment")
+		}
+		tests := []struct {
+			frame    int
+			input    string
+			expected string
+		}{
+			{0, "a + b", "10"},
+			{1, "x", "5"},
+			{2, "r", "ERROR"},
+			{2, "add(1, 2)", "3"},
+			{0, "let a = 100; a", "100"},
+			{0, "a +", "ERROR"},
+			{3, "1", "ERROR"},
+		}
+		for _, tt := range tests {
+			result, err := d.Evaluate(tt.frame, tt.input)
+			got := ""
+			if err != nil {
+				got = "ERROR"
+			} else {
+				got = result.Inspect()
+			}
+			if got != tt.expected {
+				t.Errorf("Evaluate(%d, %q) = %s (%v), want %s", tt.frame, tt.input, got, err, tt.expected)
+			}
+		}
+	}
+	f.actions = []Action{Continue, Continue}
+	out, err := runDebugged(t, path, f, func(d *Debugger) { d.SetBreakpoint("", 2) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checked {
+		t.Fatalf("breakpoint was not hit: %v", f.stops)
+	}
+	// 在调试器中修改的 a 影响了后面的执行
+	if out != "105\n" {
+		t.Errorf("wrong output %q", out)
+	}
+}
+
+func TestQuit(t *testing.T) {
+	path := writeScript(t, "main.mk", "puts(1);\nputs(2);\n")
+	f := &scriptedFrontend{actions: []Action{StepOver, Quit}}
+	out, err := runDebugged(t, path, f, nil)
+	if err == nil || err.Error() != ErrQuit.Error() {
+		t.Errorf("expected ErrQuit, got %v", err)
+	}
+	if out != "1\n" {
+		t.Errorf("wrong output %q", out)
+	}
+}
+
+func TestCLI(t *testing.T) {
+	path := writeScript(t, "main.mk", testScript)
+	commands := strings.Join([]string{
+		"help",
+		"b 2",
+		"break",
+		"c",
+		"bt",
+		"p a * b",
+		"env",
+		"f 1",
+		"p x",
+		"list",
+		"frame 9",
+		"p nope",
+		"bogus",
+		"d 2",
+		"d 2",
+		"n",
+		"",
+		"c",
+	}, "\n")
+	var out bytes.Buffer
+	i := interp.New(interp.WithStdout(&out))
+	New(i.Evaluator(), NewCLI(strings.NewReader(commands), &out))
+	wd, _ := os.Getwd()
+	os.Chdir(filepath.Dir(path))
+	defer os.Chdir(wd)
+	if _, err := i.RunFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"stopped at main.mk:1:1 in <main> (entry)\n=>    1  let add = fn(a, b) {\n(mdb) ",
+		"c, continue          run until the next breakpoint",
+		"breakpoint set at main.mk:2\n",
+		"(mdb) main.mk:2\n",
+		"stopped at main.mk:2:3 in add (breakpoint)\n=>    2    let sum = a + b;\n",
+		"* #0 add at main.mk:2:3\n  #1 twice at main.mk:6:3\n  #2 <main> at main.mk:9:1\n",
+		"(mdb) 25\n",
+		"local:\n  a = 5\n  b = 5\nglobal:\n  add = fn(a, b)\n  twice = fn(x)\n",
+		"#1 twice at main.mk:6:3\n=>    6    let y = add(x, x);\n",
+		"(mdb) 5\n",
+		"     1  let add = fn(a, b) {\n",
+		"=>    6    let y = add(x, x);\n",
+		"    11  \n(mdb) ",
+		`invalid frame "9", there are 3 frames`,
+		"error: identifier not found: nope",
+		`unknown command "bogus"`,
+		"breakpoint at 2 deleted\n(mdb) no breakpoint at 2\n",
+		"stopped at main.mk:3:3 in add (step)",
+		"stopped at main.mk:7:3 in twice (step)",
+		"(mdb) 10\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q.\noutput:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCLIQuitOnEOF(t *testing.T) {
+	path := writeScript(t, "main.mk", "puts(1);")
+	var out bytes.Buffer
+	i := interp.New(interp.WithStdout(&out))
+	New(i.Evaluator(), NewCLI(strings.NewReader(""), &out))
+	if _, err := i.RunFile(path); err == nil || err.Error() != ErrQuit.Error() {
+		t.Errorf("expected ErrQuit, got %v", err)
+	}
+	if strings.Contains(out.String(), "1\n") {
+		t.Errorf("program continued after EOF: %q", out.String())
+	}
+}
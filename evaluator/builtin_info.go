@@ -0,0 +1,57 @@
+package evaluator
+
+import "sort"
+
+// 内置函数接受的参数个数，max 为 -1 表示不限
+type Arity struct {
+	Min, Max int
+}
+
+var builtinArities = map[string]Arity{
+	"len": {1, 1}, "first": {1, 1}, "last": {1, 1}, "rest": {1, 1}, "push": {2, 2},
+
+	"map": {2, 2}, "filter": {2, 2}, "reduce": {2, 3}, "find": {2, 2},
+	"any": {2, 2}, "all": {2, 2}, "sort": {1, 2}, "sort_by": {2, 2},
+
+	"split": {1, 2}, "join": {1, 2}, "trim": {1, 1}, "trim_left": {1, 1}, "trim_right": {1, 1},
+	"upper": {1, 1}, "lower": {1, 1}, "contains": {2, 2}, "starts_with": {2, 2}, "ends_with": {2, 2},
+	"index_of": {2, 2}, "replace": {3, 4}, "repeat": {2, 2}, "substr": {2, 3}, "format": {1, -1},
+	"chars": {1, 1}, "bytes": {1, 1}, "ord": {1, 1}, "chr": {1, 1},
+
+	"type": {1, 1}, "str": {1, 1}, "int": {1, 1}, "float": {1, 1}, "bool": {1, 1},
+	"is_integer": {1, 1}, "is_float": {1, 1}, "is_string": {1, 1}, "is_bool": {1, 1},
+	"is_array": {1, 1}, "is_hash": {1, 1}, "is_null": {1, 1}, "is_function": {1, 1},
+
+	"puts": {0, -1}, "print": {0, -1}, "printf": {1, -1}, "eprint": {0, -1},
+
+	"json_parse": {1, 1}, "json_stringify": {1, 2},
+
+	"read_file": {1, 1}, "write_file": {2, 2}, "list_dir": {1, 1}, "exists": {1, 1},
+	"path_join": {0, -1}, "path_base": {1, 1}, "path_dir": {1, 1}, "path_ext": {1, 1},
+
+	"getenv": {1, 1}, "exec": {1, -1}, "time": {0, 0}, "time_ms": {0, 0}, "random": {1, 1},
+
+	"assert": {1, 2}, "assert_eq": {2, 3},
+}
+
+// 所有默认的内置函数的名字（包括需要能力的），按字母排序
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins)+len(evaluatorBuiltins)+len(capabilityBuiltins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range evaluatorBuiltins {
+		names = append(names, name)
+	}
+	for name := range capabilityBuiltins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 内置函数接受的参数个数，用于静态检查
+func BuiltinArity(name string) (Arity, bool) {
+	arity, ok := builtinArities[name]
+	return arity, ok
+}
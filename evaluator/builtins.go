@@ -1,11 +1,23 @@
 package evaluator
 
 import (
-	"fmt"
 	"monkey/object"
-	// "unicode/utf8"
+	"unicode/utf8"
 )
 
+// 需要访问求值器的内置函数（如回调 monkey 函数），创建求值器时绑定
+type evaluatorBuiltin func(e *Evaluator, args ...object.Object) object.Object
+
+var evaluatorBuiltins = map[string]evaluatorBuiltin{}
+
+// 把内置函数绑定到求值器上
+func (e *Evaluator) bind(fn evaluatorBuiltin) *object.Builtin {
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return fn(e, args...)
+	}}
+}
+
+// 不依赖求值器状态的内置函数，所有求值器共享
 var builtins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
@@ -17,7 +29,7 @@ var builtins = map[string]*object.Builtin{
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
@@ -98,12 +110,4 @@ var builtins = map[string]*object.Builtin{
 			return &object.Array{Elements: newElements}
 		},
 	},
-	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
-			}
-			return NULL
-		},
-	},
 }
@@ -0,0 +1,257 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"sort"
+)
+
+// 高阶数组内置函数需要回调 monkey 函数，绑定到具体的求值器上
+func init() {
+	evaluatorBuiltins["map"] = builtinMap
+	evaluatorBuiltins["filter"] = builtinFilter
+	evaluatorBuiltins["reduce"] = builtinReduce
+	evaluatorBuiltins["find"] = builtinFind
+	evaluatorBuiltins["any"] = builtinAny
+	evaluatorBuiltins["all"] = builtinAll
+	evaluatorBuiltins["sort"] = builtinSort
+	evaluatorBuiltins["sort_by"] = builtinSortBy
+}
+
+// 检查参数形如 (array, function)，返回数组和函数
+func arrayAndFunctionArgs(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, nil, newError("argument to `%s` must be ARRAY, got %s",
+			name, args[0].Type())
+	}
+	if !isCallable(args[1]) {
+		return nil, nil, newError("argument to `%s` must be FUNCTION, got %s",
+			name, args[1].Type())
+	}
+	return arr, args[1], nil
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Function, *object.Builtin:
+		return true
+	default:
+		return false
+	}
+}
+
+// map(arr, fn)：对每个元素调用 fn，返回新数组
+func builtinMap(e *Evaluator, args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunctionArgs("map", args)
+	if err != nil {
+		return err
+	}
+	result := make([]object.Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		val := e.applyFunction(fn, []object.Object{el})
+		if isError(val) {
+			return val
+		}
+		result[i] = val
+	}
+	return &object.Array{Elements: result}
+}
+
+// filter(arr, fn)：保留 fn 返回真值的元素
+func builtinFilter(e *Evaluator, args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunctionArgs("filter", args)
+	if err != nil {
+		return err
+	}
+	result := []object.Object{}
+	for _, el := range arr.Elements {
+		val := e.applyFunction(fn, []object.Object{el})
+		if isError(val) {
+			return val
+		}
+		if isTruthy(val) {
+			result = append(result, el)
+		}
+	}
+	return &object.Array{Elements: result}
+}
+
+// reduce(arr, fn, initial)：fn(acc, el) 依次累积，省略 initial 时以第一个元素为初值
+func builtinReduce(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3",
+			len(args))
+	}
+	arr, fn, err := arrayAndFunctionArgs("reduce", args[:2])
+	if err != nil {
+		return err
+	}
+	elements := arr.Elements
+	var acc object.Object
+	if len(args) == 3 {
+		acc = args[2]
+	} else {
+		if len(elements) == 0 {
+			return newError("`reduce` of empty array with no initial value")
+		}
+		acc = elements[0]
+		elements = elements[1:]
+	}
+	for _, el := range elements {
+		acc = e.applyFunction(fn, []object.Object{acc, el})
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+// find(arr, fn)：返回第一个使 fn 为真的元素，没有则返回 null
+func builtinFind(e *Evaluator, args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunctionArgs("find", args)
+	if err != nil {
+		return err
+	}
+	for _, el := range arr.Elements {
+		val := e.applyFunction(fn, []object.Object{el})
+		if isError(val) {
+			return val
+		}
+		if isTruthy(val) {
+			return el
+		}
+	}
+	return NULL
+}
+
+// any(arr, fn)：是否存在使 fn 为真的元素
+func builtinAny(e *Evaluator, args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunctionArgs("any", args)
+	if err != nil {
+		return err
+	}
+	for _, el := range arr.Elements {
+		val := e.applyFunction(fn, []object.Object{el})
+		if isError(val) {
+			return val
+		}
+		if isTruthy(val) {
+			return TRUE
+		}
+	}
+	return FALSE
+}
+
+// all(arr, fn)：是否所有元素都使 fn 为真
+func builtinAll(e *Evaluator, args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunctionArgs("all", args)
+	if err != nil {
+		return err
+	}
+	for _, el := range arr.Elements {
+		val := e.applyFunction(fn, []object.Object{el})
+		if isError(val) {
+			return val
+		}
+		if !isTruthy(val) {
+			return FALSE
+		}
+	}
+	return TRUE
+}
+
+// sort(arr) 或 sort(arr, less)：稳定排序，返回新数组。
+// less(a, b) 返回 a 是否应排在 b 前面；不传时按整数或字符串的自然顺序比较
+func builtinSort(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to `sort` must be ARRAY, got %s",
+			args[0].Type())
+	}
+	less := compareObjects
+	if len(args) == 2 {
+		fn := args[1]
+		if !isCallable(fn) {
+			return newError("argument to `sort` must be FUNCTION, got %s",
+				fn.Type())
+		}
+		less = func(a, b object.Object) (bool, object.Object) {
+			val := e.applyFunction(fn, []object.Object{a, b})
+			if isError(val) {
+				return false, val
+			}
+			return isTruthy(val), nil
+		}
+	}
+	return sortObjects(arr.Elements, less)
+}
+
+// sort_by(arr, key)：按 key(el) 的自然顺序稳定排序
+func builtinSortBy(e *Evaluator, args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunctionArgs("sort_by", args)
+	if err != nil {
+		return err
+	}
+	// 每个元素只计算一次 key
+	keys := make(map[object.Object]object.Object, len(arr.Elements))
+	for _, el := range arr.Elements {
+		if _, ok := keys[el]; ok {
+			continue
+		}
+		key := e.applyFunction(fn, []object.Object{el})
+		if isError(key) {
+			return key
+		}
+		keys[el] = key
+	}
+	return sortObjects(arr.Elements, func(a, b object.Object) (bool, object.Object) {
+		return compareObjects(keys[a], keys[b])
+	})
+}
+
+// 对元素做稳定排序，less 出错时中止并返回错误
+func sortObjects(
+	elements []object.Object,
+	less func(a, b object.Object) (bool, object.Object),
+) object.Object {
+	sorted := make([]object.Object, len(elements))
+	copy(sorted, elements)
+	var sortErr object.Object
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		result, err := less(sorted[i], sorted[j])
+		if err != nil {
+			sortErr = err
+			return false
+		}
+		return result
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+	return &object.Array{Elements: sorted}
+}
+
+// 自然顺序比较，只支持同类型的整数或字符串
+func compareObjects(a, b object.Object) (bool, object.Object) {
+	switch {
+	case a.Type() == object.INTEGER_OBJ && b.Type() == object.INTEGER_OBJ:
+		return a.(*object.Integer).Value < b.(*object.Integer).Value, nil
+	case isNumber(a) && isNumber(b):
+		return toFloat(a) < toFloat(b), nil
+	case a.Type() == object.STRING_OBJ && b.Type() == object.STRING_OBJ:
+		return a.(*object.String).Value < b.(*object.String).Value, nil
+	default:
+		return false, newError("cannot compare %s with %s", a.Type(), b.Type())
+	}
+}
@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"strings"
+)
+
+// 断言相关的内置函数，断言失败时返回错误，中止当前的执行
+func init() {
+	builtins["assert"] = &object.Builtin{Fn: builtinAssert}
+	builtins["assert_eq"] = &object.Builtin{Fn: builtinAssertEq}
+}
+
+// assert(cond[, message])：cond 为假时失败
+func builtinAssert(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+	if isTruthy(args[0]) {
+		return NULL
+	}
+	if len(args) == 2 {
+		return newError("assertion failed: %s", messageOf(args[1]))
+	}
+	return newError("assertion failed")
+}
+
+// assert_eq(actual, expected[, message])：两个值不相等时失败，错误信息中包含两者 Inspect 结果的差异。
+// 数组和 hash 逐个比较元素，整数和浮点数与 == 一样按数值比较，其他值比较类型和值，函数等比较是否是同一个对象
+func builtinAssertEq(args ...object.Object) object.Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3",
+			len(args))
+	}
+	actual, expected := args[0], args[1]
+	if objectsEqual(actual, expected) {
+		return NULL
+	}
+	message := "values are not equal"
+	if len(args) == 3 {
+		message = messageOf(args[2])
+	}
+	return newError("assertion failed: %s\n%s", message,
+		lineDiff(describe(expected), describe(actual)))
+}
+
+func messageOf(obj object.Object) string {
+	if s, ok := obj.(*object.String); ok {
+		return s.Value
+	}
+	return obj.Inspect()
+}
+
+// Inspect 的结果，字符串加上引号以便和其他类型区分
+func describe(obj object.Object) string {
+	if s, ok := obj.(*object.String); ok {
+		return `"` + s.Value + `"`
+	}
+	return obj.Inspect()
+}
+
+// 比较两个值是否相等
+func objectsEqual(a, b object.Object) bool {
+	if isNumber(a) && isNumber(b) {
+		return toFloat(a) == toFloat(b)
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	switch a := a.(type) {
+	case *object.Boolean:
+		return a.Value == b.(*object.Boolean).Value
+	case *object.String:
+		return a.Value == b.(*object.String).Value
+	case *object.Null:
+		return true
+	case *object.Array:
+		other := b.(*object.Array)
+		if len(a.Elements) != len(other.Elements) {
+			return false
+		}
+		for i, el := range a.Elements {
+			if !objectsEqual(el, other.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		other := b.(*object.Hash)
+		if len(a.Pairs) != len(other.Pairs) {
+			return false
+		}
+		for key, pair := range a.Pairs {
+			otherPair, ok := other.Pairs[key]
+			if !ok || !objectsEqual(pair.Value, otherPair.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// 按行比较 expected 和 actual，输出类似 diff -u 的结果：
+// 相同的行以空格开头，只在 expected 中的行以 - 开头，只在 actual 中的行以 + 开头
+func lineDiff(expected, actual string) string {
+	a, b := strings.Split(expected, "\n"), strings.Split(actual, "\n")
+	// lcs[i][j] 是 a[i:] 和 b[j:] 的最长公共子序列的长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("--- expected\n+++ actual")
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("\n " + a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			out.WriteString("\n-" + a[i])
+			i++
+		default:
+			out.WriteString("\n+" + b[j])
+			j++
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,126 @@
+package evaluator
+
+import (
+	"io"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// 文件系统相关的内置函数。读写文件需要 fs-read / fs-write 能力，路径处理不需要
+func init() {
+	capabilityBuiltins["read_file"] = capabilityBuiltin{CapFSRead, builtinReadFile}
+	capabilityBuiltins["list_dir"] = capabilityBuiltin{CapFSRead, builtinListDir}
+	capabilityBuiltins["exists"] = capabilityBuiltin{CapFSRead, builtinExists}
+	capabilityBuiltins["write_file"] = capabilityBuiltin{CapFSWrite, builtinWriteFile}
+
+	builtins["path_join"] = &object.Builtin{Fn: builtinPathJoin}
+	builtins["path_base"] = &object.Builtin{Fn: stringTransform("path_base", filepath.Base)}
+	builtins["path_dir"] = &object.Builtin{Fn: stringTransform("path_dir", filepath.Dir)}
+	builtins["path_ext"] = &object.Builtin{Fn: stringTransform("path_ext", filepath.Ext)}
+}
+
+// read_file(path)：读取整个文件的内容
+func builtinReadFile(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	path, err := stringArg("read_file", args, 0)
+	if err != nil {
+		return err
+	}
+	f, osErr := os.Open(path)
+	if osErr != nil {
+		return newError("read_file: %s", osErr)
+	}
+	defer f.Close()
+	if info, osErr := f.Stat(); osErr == nil && info.Mode().IsRegular() {
+		if err := e.checkResult("read_file", info.Size()); err != nil {
+			return err
+		}
+	}
+	// 设备等文件的大小事先未知，读取时限制大小
+	data := &limitedBuffer{limit: e.available()}
+	if _, osErr := io.Copy(data, f); osErr != nil {
+		if data.exceeded {
+			return e.checkResult("read_file", data.limit+1)
+		}
+		return newError("read_file: %s", osErr)
+	}
+	return &object.String{Value: data.String()}
+}
+
+// write_file(path, content)：写入文件，文件已存在时覆盖
+func builtinWriteFile(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	path, err := stringArg("write_file", args, 0)
+	if err != nil {
+		return err
+	}
+	content, err := stringArg("write_file", args, 1)
+	if err != nil {
+		return err
+	}
+	if osErr := os.WriteFile(path, []byte(content), 0o644); osErr != nil {
+		return newError("write_file: %s", osErr)
+	}
+	return NULL
+}
+
+// list_dir(path)：目录下的文件名，按名字排序
+func builtinListDir(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	path, err := stringArg("list_dir", args, 0)
+	if err != nil {
+		return err
+	}
+	entries, osErr := os.ReadDir(path)
+	if osErr != nil {
+		return newError("list_dir: %s", osErr)
+	}
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	sort.Strings(names)
+	elements := make([]object.Object, len(names))
+	for i, name := range names {
+		elements[i] = &object.String{Value: name}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// exists(path)：文件或目录是否存在
+func builtinExists(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	path, err := stringArg("exists", args, 0)
+	if err != nil {
+		return err
+	}
+	_, osErr := os.Stat(path)
+	return nativeBoolToBooleanObject(osErr == nil)
+}
+
+// path_join(parts...)：拼接路径
+func builtinPathJoin(args ...object.Object) object.Object {
+	parts := make([]string, len(args))
+	for i := range args {
+		part, err := stringArg("path_join", args, i)
+		if err != nil {
+			return err
+		}
+		parts[i] = part
+	}
+	return &object.String{Value: filepath.Join(parts...)}
+}
@@ -0,0 +1,58 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"monkey/object"
+)
+
+// 输出相关的内置函数，写入求值器配置的 stdout/stderr
+func init() {
+	evaluatorBuiltins["puts"] = builtinPuts
+	evaluatorBuiltins["print"] = builtinPrint
+	evaluatorBuiltins["printf"] = builtinPrintf
+	evaluatorBuiltins["eprint"] = builtinEprint
+}
+
+// puts(args...)：每个参数输出一行
+func builtinPuts(e *Evaluator, args ...object.Object) object.Object {
+	for _, arg := range args {
+		text, err := e.toText(arg)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, text)
+	}
+	return NULL
+}
+
+// print(args...)：依次输出参数，不换行
+func builtinPrint(e *Evaluator, args ...object.Object) object.Object {
+	return e.writeObjects(e.stdout, args)
+}
+
+// printf(fmt, args...)：按 format 的规则格式化后输出，不换行
+func builtinPrintf(e *Evaluator, args ...object.Object) object.Object {
+	formatted, err := e.formatObjects("printf", args)
+	if err != nil {
+		return err
+	}
+	io.WriteString(e.stdout, formatted)
+	return NULL
+}
+
+// eprint(args...)：与 print 相同，但输出到 stderr
+func builtinEprint(e *Evaluator, args ...object.Object) object.Object {
+	return e.writeObjects(e.stderr, args)
+}
+
+func (e *Evaluator) writeObjects(w io.Writer, args []object.Object) object.Object {
+	for _, arg := range args {
+		text, err := e.toText(arg)
+		if err != nil {
+			return err
+		}
+		io.WriteString(w, text)
+	}
+	return NULL
+}
@@ -0,0 +1,231 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"monkey/object"
+	"strconv"
+	"strings"
+)
+
+// JSON 编码与解码
+func init() {
+	builtins["json_parse"] = &object.Builtin{Fn: builtinJSONParse}
+	evaluatorBuiltins["json_stringify"] = builtinJSONStringify
+}
+
+// json_parse(s)：解析 JSON。
+// 对象解析为 hash（保留 key 的顺序），数组为 array，
+// 不带小数点和指数的数字为整数，其他数字为浮点数，null 为 null
+func builtinJSONParse(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	s, err := stringArg("json_parse", args, 0)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	value, decErr := decodeJSON(dec)
+	if decErr == nil {
+		if _, extra := dec.Token(); extra != io.EOF {
+			decErr = errors.New("unexpected data after top-level value")
+		}
+	}
+	if decErr != nil {
+		if decErr == io.EOF {
+			decErr = errors.New("unexpected end of JSON input")
+		}
+		return newError("json_parse: %s", decErr)
+	}
+	return value
+}
+
+// 从 dec 中读取一个完整的 JSON 值
+func decodeJSON(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		return nativeBoolToBooleanObject(tok), nil
+	case string:
+		return &object.String{Value: tok}, nil
+	case json.Number:
+		return jsonNumber(tok)
+	case json.Delim:
+		switch tok {
+		case '[':
+			elements := []object.Object{}
+			for dec.More() {
+				el, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, el)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return &object.Array{Elements: elements}, nil
+		case '{':
+			hash := object.NewHash()
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				hash.Set(&object.String{Value: key.(string)}, value)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return hash, nil
+		}
+	}
+	return nil, errors.New("unexpected token " + strconv.Quote(tok.(json.Delim).String()))
+}
+
+func jsonNumber(n json.Number) (object.Object, error) {
+	if !strings.ContainsAny(string(n), ".eE") {
+		if value, err := n.Int64(); err == nil {
+			return &object.Integer{Value: value}, nil
+		}
+	}
+	value, err := n.Float64()
+	if err != nil {
+		return nil, errors.New("number out of range: " + string(n))
+	}
+	return &object.Float{Value: value}, nil
+}
+
+// json_stringify(value, indent)：编码为 JSON。
+// indent 可以是缩进的空格数或缩进字符串，省略时输出紧凑的单行 JSON。
+// hash 的 key 必须是字符串，函数等无法表示为 JSON 的值会返回错误。
+// 缩进时结果可能比值本身大得多，编码过程中随时检查内存预算
+func builtinJSONStringify(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *object.Integer:
+			if arg.Value < 0 || arg.Value > 10 {
+				return newError("json_stringify: indent must be between 0 and 10, got %d", arg.Value)
+			}
+			indent = strings.Repeat(" ", int(arg.Value))
+		case *object.String:
+			indent = arg.Value
+		default:
+			return newError("argument to `json_stringify` must be INTEGER or STRING, got %s",
+				args[1].Type())
+		}
+	}
+	var out bytes.Buffer
+	if err := e.encodeJSON(&out, args[0], indent, ""); err != nil {
+		return err
+	}
+	if err := e.checkResult("json_stringify", int64(out.Len())); err != nil {
+		return err
+	}
+	return &object.String{Value: out.String()}
+}
+
+func (e *Evaluator) encodeJSON(out *bytes.Buffer, obj object.Object, indent, prefix string) *object.Error {
+	if err := e.checkResult("json_stringify", int64(out.Len())); err != nil {
+		return err
+	}
+	// 缩进时每个元素单独一行
+	newline := func(prefix string) {
+		if indent != "" {
+			out.WriteByte('\n')
+			out.WriteString(prefix)
+		}
+	}
+	switch obj := obj.(type) {
+	case *object.Null:
+		out.WriteString("null")
+	case *object.Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return newError("json_stringify: cannot encode %s", obj.Inspect())
+		}
+		out.WriteString(obj.Inspect())
+	case *object.String:
+		writeJSONString(out, obj.Value)
+	case *object.Array:
+		if len(obj.Elements) == 0 {
+			out.WriteString("[]")
+			return nil
+		}
+		out.WriteByte('[')
+		for i, el := range obj.Elements {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			newline(prefix + indent)
+			if err := e.encodeJSON(out, el, indent, prefix+indent); err != nil {
+				return err
+			}
+		}
+		newline(prefix)
+		out.WriteByte(']')
+	case *object.Hash:
+		pairs := obj.OrderedPairs()
+		if len(pairs) == 0 {
+			out.WriteString("{}")
+			return nil
+		}
+		out.WriteByte('{')
+		for i, pair := range pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError("json_stringify: hash key must be STRING, got %s (%s)",
+					pair.Key.Type(), pair.Key.Inspect())
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			newline(prefix + indent)
+			writeJSONString(out, key.Value)
+			out.WriteByte(':')
+			if indent != "" {
+				out.WriteByte(' ')
+			}
+			if err := e.encodeJSON(out, pair.Value, indent, prefix+indent); err != nil {
+				return err
+			}
+		}
+		newline(prefix)
+		out.WriteByte('}')
+	default:
+		return newError("json_stringify: cannot encode %s", obj.Type())
+	}
+	return nil
+}
+
+// 写入 JSON 字符串，不转义 HTML 字符
+func writeJSONString(out *bytes.Buffer, s string) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	out.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+}
@@ -0,0 +1,128 @@
+package evaluator
+
+import (
+	"errors"
+	"monkey/object"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// 访问环境变量、进程、时间和随机数的内置函数，需要授予对应的能力
+func init() {
+	capabilityBuiltins["getenv"] = capabilityBuiltin{CapEnv, builtinGetenv}
+	capabilityBuiltins["exec"] = capabilityBuiltin{CapExec, builtinExec}
+	capabilityBuiltins["time"] = capabilityBuiltin{CapClock, builtinTime}
+	capabilityBuiltins["time_ms"] = capabilityBuiltin{CapClock, builtinTimeMs}
+	capabilityBuiltins["random"] = capabilityBuiltin{CapRandom, builtinRandom}
+}
+
+// getenv(name)：环境变量的值，不存在时返回 null
+func builtinGetenv(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	name, err := stringArg("getenv", args, 0)
+	if err != nil {
+		return err
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return NULL
+	}
+	return &object.String{Value: value}
+}
+
+// exec(cmd, args...)：执行外部命令，返回 {"stdout": ..., "stderr": ..., "code": ...}
+func builtinExec(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got=%d, want at least 1",
+			len(args))
+	}
+	strs := make([]string, len(args))
+	for i := range args {
+		s, err := stringArg("exec", args, i)
+		if err != nil {
+			return err
+		}
+		strs[i] = s
+	}
+	ctx := e.run.ctx
+	if ctx == nil {
+		return newError("exec called outside of evaluation")
+	}
+	// 输出超出内存预算时中止命令
+	stdout := &limitedBuffer{limit: e.available()}
+	stderr := &limitedBuffer{limit: e.available()}
+	cmd := exec.CommandContext(ctx, strs[0], strs[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	code := 0
+	err := cmd.Run()
+	// 输出被截断时命令通常因为管道关闭而失败，先报告超出预算
+	if stdout.exceeded || stderr.exceeded {
+		return e.checkResult("exec", stdout.limit+1)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return newError("exec %s: %s", strs[0], err)
+		}
+		code = exitErr.ExitCode()
+	}
+	return newStringHash(map[string]object.Object{
+		"stdout": &object.String{Value: stdout.String()},
+		"stderr": &object.String{Value: stderr.String()},
+		"code":   &object.Integer{Value: int64(code)},
+	})
+}
+
+// time()：当前的 unix 时间（秒）
+func builtinTime(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0",
+			len(args))
+	}
+	return &object.Integer{Value: time.Now().Unix()}
+}
+
+// time_ms()：当前的 unix 时间（毫秒）
+func builtinTimeMs(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0",
+			len(args))
+	}
+	return &object.Integer{Value: time.Now().UnixNano() / int64(time.Millisecond)}
+}
+
+// random(n)：[0, n) 之间的随机整数
+func builtinRandom(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	n, err := integerArg("random", args, 0)
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return newError("argument to `random` must be positive, got %d", n)
+	}
+	return &object.Integer{Value: e.rand.Int63n(n)}
+}
+
+// 用字符串 key 构造 hash，key 按字典序插入
+func newStringHash(values map[string]object.Object) *object.Hash {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	hash := object.NewHash()
+	for _, k := range keys {
+		hash.Set(&object.String{Value: k}, values[k])
+	}
+	return hash
+}
@@ -0,0 +1,414 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"monkey/object"
+	"strings"
+	"unicode/utf8"
+)
+
+// 字符串相关的内置函数。结果可能比参数大得多的函数在分配结果之前检查内存预算
+func init() {
+	evaluatorBuiltins["split"] = builtinSplit
+	evaluatorBuiltins["join"] = builtinJoin
+	builtins["trim"] = &object.Builtin{Fn: stringTransform("trim", strings.TrimSpace)}
+	builtins["trim_left"] = &object.Builtin{Fn: stringTransform("trim_left", func(s string) string {
+		return strings.TrimLeft(s, " \t\r\n")
+	})}
+	builtins["trim_right"] = &object.Builtin{Fn: stringTransform("trim_right", func(s string) string {
+		return strings.TrimRight(s, " \t\r\n")
+	})}
+	builtins["upper"] = &object.Builtin{Fn: stringTransform("upper", strings.ToUpper)}
+	builtins["lower"] = &object.Builtin{Fn: stringTransform("lower", strings.ToLower)}
+	builtins["contains"] = &object.Builtin{Fn: stringPredicate("contains", strings.Contains)}
+	builtins["starts_with"] = &object.Builtin{Fn: stringPredicate("starts_with", strings.HasPrefix)}
+	builtins["ends_with"] = &object.Builtin{Fn: stringPredicate("ends_with", strings.HasSuffix)}
+	builtins["index_of"] = &object.Builtin{Fn: builtinIndexOf}
+	evaluatorBuiltins["replace"] = builtinReplace
+	evaluatorBuiltins["repeat"] = builtinRepeat
+	builtins["substr"] = &object.Builtin{Fn: builtinSubstr}
+	evaluatorBuiltins["format"] = builtinFormat
+	evaluatorBuiltins["chars"] = builtinChars
+	evaluatorBuiltins["bytes"] = builtinBytes
+	builtins["ord"] = &object.Builtin{Fn: builtinOrd}
+	builtins["chr"] = &object.Builtin{Fn: builtinChr}
+}
+
+// 取第 i 个参数作为字符串
+func stringArg(name string, args []object.Object, i int) (string, *object.Error) {
+	str, ok := args[i].(*object.String)
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got %s",
+			name, args[i].Type())
+	}
+	return str.Value, nil
+}
+
+// 取第 i 个参数作为整数
+func integerArg(name string, args []object.Object, i int) (int64, *object.Error) {
+	integer, ok := args[i].(*object.Integer)
+	if !ok {
+		return 0, newError("argument to `%s` must be INTEGER, got %s",
+			name, args[i].Type())
+	}
+	return integer.Value, nil
+}
+
+// 单个字符串参数、返回新字符串的内置函数
+func stringTransform(name string, fn func(string) string) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1",
+				len(args))
+		}
+		s, err := stringArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: fn(s)}
+	}
+}
+
+// 两个字符串参数、返回布尔值的内置函数
+func stringPredicate(name string, fn func(string, string) bool) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2",
+				len(args))
+		}
+		s, err := stringArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+		sub, err := stringArg(name, args, 1)
+		if err != nil {
+			return err
+		}
+		return nativeBoolToBooleanObject(fn(s, sub))
+	}
+}
+
+// split(s, sep)：按 sep 切分；省略 sep 时按空白切分
+func builtinSplit(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+	s, err := stringArg("split", args, 0)
+	if err != nil {
+		return err
+	}
+	var parts []string
+	if len(args) == 2 {
+		sep, err := stringArg("split", args, 1)
+		if err != nil {
+			return err
+		}
+		n := strings.Count(s, sep) + 1
+		if sep == "" {
+			n = utf8.RuneCountInString(s)
+		}
+		if err := e.checkResult("split", arraySize(n, len(s))); err != nil {
+			return err
+		}
+		parts = strings.Split(s, sep)
+	} else {
+		parts = strings.Fields(s)
+	}
+	elements := make([]object.Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &object.String{Value: part}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// join(arr, sep)：用 sep 连接字符串数组
+func builtinJoin(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2",
+			len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to `join` must be ARRAY, got %s",
+			args[0].Type())
+	}
+	sep := ""
+	if len(args) == 2 {
+		var err *object.Error
+		sep, err = stringArg("join", args, 1)
+		if err != nil {
+			return err
+		}
+	}
+	parts := make([]string, len(arr.Elements))
+	size := int64(0)
+	for i, el := range arr.Elements {
+		str, ok := el.(*object.String)
+		if !ok {
+			return newError("`join` requires an array of STRING, got %s at index %d",
+				el.Type(), i)
+		}
+		parts[i] = str.Value
+		size += int64(len(str.Value))
+	}
+	if len(parts) > 1 {
+		size += int64(len(sep)) * int64(len(parts)-1)
+	}
+	if err := e.checkResult("join", size); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Join(parts, sep)}
+}
+
+// index_of(s, sub)：sub 第一次出现的位置（按字符计），不存在返回 -1
+func builtinIndexOf(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	s, err := stringArg("index_of", args, 0)
+	if err != nil {
+		return err
+	}
+	sub, err := stringArg("index_of", args, 1)
+	if err != nil {
+		return err
+	}
+	idx := strings.Index(s, sub)
+	if idx > 0 {
+		idx = utf8.RuneCountInString(s[:idx])
+	}
+	return &object.Integer{Value: int64(idx)}
+}
+
+// replace(s, old, new)：替换所有 old；可选第四个参数限制替换次数
+func builtinReplace(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 3 && len(args) != 4 {
+		return newError("wrong number of arguments. got=%d, want=3 or 4",
+			len(args))
+	}
+	strs := make([]string, 3)
+	for i := range strs {
+		s, err := stringArg("replace", args, i)
+		if err != nil {
+			return err
+		}
+		strs[i] = s
+	}
+	n := int64(-1)
+	if len(args) == 4 {
+		var err *object.Error
+		n, err = integerArg("replace", args, 3)
+		if err != nil {
+			return err
+		}
+	}
+	// old 为空时在每个字符前后插入 new
+	count := int64(strings.Count(strs[0], strs[1]))
+	if strs[1] == "" {
+		count = int64(utf8.RuneCountInString(strs[0])) + 1
+	}
+	if n >= 0 && n < count {
+		count = n
+	}
+	size := int64(len(strs[0])) + count*(int64(len(strs[2]))-int64(len(strs[1])))
+	if err := e.checkResult("replace", size); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Replace(strs[0], strs[1], strs[2], int(n))}
+}
+
+// repeat(s, n)：将 s 重复 n 次。结果的大小在分配之前检查，超出内存预算时直接失败
+func builtinRepeat(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	s, err := stringArg("repeat", args, 0)
+	if err != nil {
+		return err
+	}
+	n, err := integerArg("repeat", args, 1)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return newError("negative repeat count: %d", n)
+	}
+	if len(s) > 0 && n > math.MaxInt32/int64(len(s)) {
+		return newError("repeat result too large: %d * %d bytes", n, len(s))
+	}
+	if err := e.checkAlloc(int64(len(s)) * n); err != nil {
+		return err
+	}
+	return &object.String{Value: strings.Repeat(s, int(n))}
+}
+
+// substr(s, start, length)：从 start 开始截取 length 个字符，省略 length 时截取到末尾
+func builtinSubstr(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3",
+			len(args))
+	}
+	s, err := stringArg("substr", args, 0)
+	if err != nil {
+		return err
+	}
+	start, err := integerArg("substr", args, 1)
+	if err != nil {
+		return err
+	}
+	runes := []rune(s)
+	size := int64(len(runes))
+	length := size - start
+	if len(args) == 3 {
+		length, err = integerArg("substr", args, 2)
+		if err != nil {
+			return err
+		}
+	}
+	if start < 0 || start > size || length < 0 {
+		return newError("substr out of range: start=%d, length=%d, len=%d",
+			start, length, size)
+	}
+	if length > size-start {
+		length = size - start
+	}
+	return &object.String{Value: string(runes[start : start+length])}
+}
+
+// format(fmt, args...)：printf 风格的格式化
+func builtinFormat(e *Evaluator, args ...object.Object) object.Object {
+	formatted, err := e.formatObjects("format", args)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: formatted}
+}
+
+// 以第一个参数为格式串格式化其余参数，name 用于错误信息。
+// 结果写入有大小上限的缓冲区，超出内存预算时返回错误
+func (e *Evaluator) formatObjects(name string, args []object.Object) (string, *object.Error) {
+	if len(args) < 1 {
+		return "", newError("wrong number of arguments. got=%d, want at least 1",
+			len(args))
+	}
+	format, err := stringArg(name, args, 0)
+	if err != nil {
+		return "", err
+	}
+	values, err := e.nativeFormatArgs(args[1:])
+	if err != nil {
+		return "", err
+	}
+	out := &limitedBuffer{limit: e.available()}
+	fmt.Fprintf(out, format, values...)
+	if out.exceeded {
+		return "", e.checkResult(name, out.limit+1)
+	}
+	return out.String(), nil
+}
+
+// 将 monkey 值转换为 fmt 可以识别的 go 值，其他类型使用输出的文本
+func (e *Evaluator) nativeFormatArgs(args []object.Object) ([]interface{}, *object.Error) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case *object.Integer:
+			values[i] = arg.Value
+		case *object.Float:
+			values[i] = arg.Value
+		case *object.Boolean:
+			values[i] = arg.Value
+		case *object.String:
+			values[i] = arg.Value
+		default:
+			text, err := e.toText(arg)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = text
+		}
+	}
+	return values, nil
+}
+
+// chars(s)：把字符串拆成单个字符组成的数组
+func builtinChars(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	s, err := stringArg("chars", args, 0)
+	if err != nil {
+		return err
+	}
+	if err := e.checkResult("chars", arraySize(utf8.RuneCountInString(s), len(s))); err != nil {
+		return err
+	}
+	elements := make([]object.Object, 0, len(s))
+	for _, r := range s {
+		elements = append(elements, &object.String{Value: string(r)})
+	}
+	return &object.Array{Elements: elements}
+}
+
+// bytes(s)：字符串 UTF-8 编码后的字节数组
+func builtinBytes(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	s, err := stringArg("bytes", args, 0)
+	if err != nil {
+		return err
+	}
+	if err := e.checkResult("bytes", arraySize(len(s), 0)); err != nil {
+		return err
+	}
+	elements := make([]object.Object, len(s))
+	for i := 0; i < len(s); i++ {
+		elements[i] = &object.Integer{Value: int64(s[i])}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// n 个元素、元素的内容共 content 字节的数组的大小，按 sizeOf 的方式估计
+func arraySize(n, content int) int64 {
+	return sizeOf(&object.Array{}) + int64(n)*(16+sizeOf(&object.String{})) + int64(content)
+}
+
+// ord(c)：单个字符的 Unicode 码点
+func builtinOrd(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	s, err := stringArg("ord", args, 0)
+	if err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return newError("argument to `ord` must be a single character, got %q", s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return &object.Integer{Value: int64(r)}
+}
+
+// chr(n)：码点对应的字符
+func builtinChr(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	n, err := integerArg("chr", args, 0)
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > utf8.MaxRune || !utf8.ValidRune(rune(n)) {
+		return newError("invalid code point: %d", n)
+	}
+	return &object.String{Value: string(rune(n))}
+}
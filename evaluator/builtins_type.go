@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"math"
+	"monkey/object"
+	"strconv"
+	"strings"
+)
+
+// 类型查询与转换相关的内置函数
+func init() {
+	builtins["type"] = &object.Builtin{Fn: builtinType}
+	evaluatorBuiltins["str"] = builtinStr
+	builtins["int"] = &object.Builtin{Fn: builtinInt}
+	builtins["float"] = &object.Builtin{Fn: builtinFloat}
+	builtins["bool"] = &object.Builtin{Fn: builtinBool}
+	builtins["is_integer"] = &object.Builtin{Fn: typePredicate(object.INTEGER_OBJ)}
+	builtins["is_float"] = &object.Builtin{Fn: typePredicate(object.FLOAT_OBJ)}
+	builtins["is_string"] = &object.Builtin{Fn: typePredicate(object.STRING_OBJ)}
+	builtins["is_bool"] = &object.Builtin{Fn: typePredicate(object.BOOLEAN_OBJ)}
+	builtins["is_array"] = &object.Builtin{Fn: typePredicate(object.ARRAY_OBJ)}
+	builtins["is_hash"] = &object.Builtin{Fn: typePredicate(object.HASH_OBJ)}
+	builtins["is_null"] = &object.Builtin{Fn: typePredicate(object.NULL_OBJ)}
+	builtins["is_function"] = &object.Builtin{Fn: typePredicate(object.FUNCTION_OBJ, object.BUILTIN_OBJ)}
+}
+
+// type(x)：返回值的类型名，如 "INTEGER"
+func builtinType(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	return &object.String{Value: string(args[0].Type())}
+}
+
+// str(x)：转换为字符串，结果和 puts 输出的文本相同
+func builtinStr(e *Evaluator, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	if arg, ok := args[0].(*object.String); ok {
+		return arg
+	}
+	text, err := e.toText(args[0])
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: text}
+}
+
+// 值输出时的文本，str、puts、print、eprint 和 format 都通过它转换。
+// 包含 "to_string" 函数的 hash 使用 to_string(x) 返回的字符串，其他值使用 Inspect 的结果
+func (e *Evaluator) toText(obj object.Object) (string, *object.Error) {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return obj.Inspect(), nil
+	}
+	hook, ok := hash.Pairs[(&object.String{Value: "to_string"}).HashKey()]
+	if !ok || !isCallable(hook.Value) {
+		return obj.Inspect(), nil
+	}
+	result := e.applyFunction(hook.Value, []object.Object{hash})
+	if err, ok := result.(*object.Error); ok {
+		return "", err
+	}
+	str, ok := result.(*object.String)
+	if !ok {
+		return "", newError("`to_string` must return STRING, got %s", result.Type())
+	}
+	return str.Value, nil
+}
+
+// int(x)：转换为整数，字符串按十进制解析，浮点数向零取整
+func builtinInt(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		// 超出 int64 范围的浮点数（包括 NaN 和 Inf）无法表示为整数
+		if math.IsNaN(arg.Value) || arg.Value >= math.MaxInt64 || arg.Value < math.MinInt64 {
+			return newError("cannot convert %s to INTEGER", arg.Inspect())
+		}
+		return &object.Integer{Value: int64(arg.Value)}
+	case *object.Boolean:
+		if arg.Value {
+			return &object.Integer{Value: 1}
+		}
+		return &object.Integer{Value: 0}
+	case *object.String:
+		value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+		if err != nil {
+			return newError("could not parse %q as integer", arg.Value)
+		}
+		return &object.Integer{Value: value}
+	default:
+		return newError("cannot convert %s to INTEGER", args[0].Type())
+	}
+}
+
+// float(x)：转换为浮点数
+func builtinFloat(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	switch arg := args[0].(type) {
+	case *object.Float:
+		return arg
+	case *object.Integer:
+		return &object.Float{Value: float64(arg.Value)}
+	case *object.String:
+		value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return newError("could not parse %q as float", arg.Value)
+		}
+		return &object.Float{Value: value}
+	default:
+		return newError("cannot convert %s to FLOAT", args[0].Type())
+	}
+}
+
+// bool(x)：按 if 条件的规则判断真假
+func builtinBool(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	return nativeBoolToBooleanObject(isTruthy(args[0]))
+}
+
+// is_* 类型判断
+func typePredicate(types ...object.ObjectType) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1",
+				len(args))
+		}
+		for _, t := range types {
+			if args[0].Type() == t {
+				return TRUE
+			}
+		}
+		return FALSE
+	}
+}
@@ -0,0 +1,112 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/object"
+	"strings"
+)
+
+// 能力，访问外部环境的内置函数必须在求值器被授予对应的能力后才能使用
+type Capability uint
+
+const (
+	CapFSRead  Capability = 1 << iota // 读文件
+	CapFSWrite                        // 写文件
+	CapEnv                            // 读环境变量
+	CapExec                           // 执行外部进程
+	CapClock                          // 读取时间
+	CapRandom                         // 随机数
+	CapImport                         // 用 import 加载脚本目录以外的模块文件
+
+	CapNone Capability = 0
+	CapAll             = CapFSRead | CapFSWrite | CapEnv | CapExec | CapClock | CapRandom | CapImport
+)
+
+var capabilityNames = []struct {
+	cap  Capability
+	name string
+}{
+	{CapFSRead, "fs-read"},
+	{CapFSWrite, "fs-write"},
+	{CapEnv, "env"},
+	{CapExec, "exec"},
+	{CapClock, "clock"},
+	{CapRandom, "random"},
+	{CapImport, "import"},
+}
+
+// 能力的名字，多个能力用逗号分隔，如 "fs-read,env"
+func (c Capability) String() string {
+	names := []string{}
+	for _, cn := range capabilityNames {
+		if c&cn.cap != 0 {
+			names = append(names, cn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// 按名字查找能力，"all" 表示全部能力
+func ParseCapability(name string) (Capability, error) {
+	if name == "all" {
+		return CapAll, nil
+	}
+	for _, cn := range capabilityNames {
+		if cn.name == name {
+			return cn.cap, nil
+		}
+	}
+	return CapNone, fmt.Errorf("unknown capability: %s", name)
+}
+
+// 所有能力的名字
+func CapabilityNames() []string {
+	names := make([]string, len(capabilityNames))
+	for i, cn := range capabilityNames {
+		names[i] = cn.name
+	}
+	return names
+}
+
+// 需要能力的内置函数
+type capabilityBuiltin struct {
+	cap Capability
+	fn  evaluatorBuiltin
+}
+
+var capabilityBuiltins = map[string]capabilityBuiltin{}
+
+// 授予求值器的能力，默认没有任何能力。
+// 没有被授予能力的内置函数仍然存在，但调用时返回错误。
+// 宿主用 RemoveBuiltin 移除或用 RegisterBuiltin 替换过的内置函数保持宿主的设置
+func (e *Evaluator) SetCapabilities(caps Capability) {
+	e.caps = caps
+	if e.capBuiltins == nil {
+		e.capBuiltins = map[string]*object.Builtin{}
+	}
+	for name, cb := range capabilityBuiltins {
+		if installed, ok := e.capBuiltins[name]; ok && e.builtins[name] != installed {
+			continue
+		}
+		builtin := deniedBuiltin(name, cb.cap)
+		if caps&cb.cap == cb.cap {
+			builtin = e.bind(cb.fn)
+		}
+		e.builtins[name] = builtin
+		e.capBuiltins[name] = builtin
+	}
+}
+
+// 已授予的能力
+func (e *Evaluator) Capabilities() Capability {
+	return e.caps
+}
+
+func deniedBuiltin(name string, cap Capability) *object.Builtin {
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return newError("capability denied: `%s` requires %s", name, cap)
+	}}
+}
@@ -1,9 +1,14 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"math/rand"
 	"monkey/ast"
 	"monkey/object"
+	"os"
+	"time"
 )
 
 var (
@@ -12,91 +17,212 @@ var (
 	NULL  = &object.Null{}
 )
 
-// 表达式求值
+// 求值器，持有内置函数、已加载的模块等运行状态。
+// 不同求值器之间互不影响，可以在多个 goroutine 中分别使用，但单个求值器不能并发使用
+type Evaluator struct {
+	limits      Limits
+	run         runState // 当前这次执行的状态
+	builtins    map[string]*object.Builtin
+	stdout      io.Writer                  // puts、print 等内置函数的输出
+	stderr      io.Writer                  // eprint 的输出
+	caps        Capability                 // 授予的能力
+	capBuiltins map[string]*object.Builtin // SetCapabilities 设置的需要能力的内置函数
+	rand        *rand.Rand
+	modules     map[string]*object.Module // 已加载的模块，key 为模块文件的绝对路径
+	importStack []string                  // 正在加载中的模块，用于检测循环 import
+	importRoot  string                    // 最外层 import 所在的脚本目录，不需要能力就能加载其中的模块
+	hooks       Hooks                     // 调试等工具使用的钩子
+}
+
+// 创建一个带有全部默认内置函数的求值器，默认不授予任何能力
+func New() *Evaluator {
+	e := &Evaluator{
+		builtins: make(map[string]*object.Builtin, len(builtins)+len(evaluatorBuiltins)),
+		modules:  map[string]*object.Module{},
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+		limits:   Limits{MaxDepth: DefaultMaxDepth},
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for name, builtin := range builtins {
+		e.builtins[name] = builtin
+	}
+	for name, fn := range evaluatorBuiltins {
+		e.builtins[name] = e.bind(fn)
+	}
+	e.SetCapabilities(CapNone)
+	return e
+}
+
+// 设置脚本输出写入的位置，默认为 os.Stdout
+func (e *Evaluator) SetStdout(w io.Writer) {
+	e.stdout = w
+}
+
+// 设置脚本错误输出写入的位置，默认为 os.Stderr
+func (e *Evaluator) SetStderr(w io.Writer) {
+	e.stderr = w
+}
+
+// 注册内置函数，同名时覆盖已有的
+func (e *Evaluator) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	e.builtins[name] = &object.Builtin{Fn: fn}
+}
+
+// 移除内置函数，如在不可信的脚本中禁用 puts
+func (e *Evaluator) RemoveBuiltin(name string) {
+	delete(e.builtins, name)
+}
+
+// 按名字查找内置函数
+func (e *Evaluator) Builtin(name string) (*object.Builtin, bool) {
+	builtin, ok := e.builtins[name]
+	return builtin, ok
+}
+
+// 使用一个新的默认求值器求值，等价于 New().Eval(node, env)
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+// 求值，受 SetLimits 设置的执行限制约束
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	return e.EvalContext(context.Background(), node, env)
+}
+
+// 求值，ctx 被取消或超时时中止执行并返回错误。
+// 每次调用都会重新计算步数和内存预算
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	defer e.begin(ctx)()
+	return e.eval(node, env)
+}
+
+// 求值一个节点，每个节点计为一步
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
+	if err := e.step(); err != nil {
+		return err
+	}
+	if err := e.enterEval(); err != nil {
+		return err
+	}
+	defer func() { e.run.level-- }()
+	if e.hooks != nil {
+		if err := e.hooks.BeforeEval(node, env); err != nil {
+			return e.abort("%s", err)
+		}
+	}
+	result := e.evalNode(node, env)
+	switch node.(type) {
+	case *ast.StringLiteral, *ast.ArrayLiteral, *ast.HashLiteral,
+		*ast.InfixExpression, *ast.SliceExpression, *ast.FunctionLiteral:
+		if err := e.alloc(result); err != nil {
+			return err
+		}
+	}
+	return result
+}
+
+// 按节点类型求值
+func (e *Evaluator) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 		// Expressions
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
+	case *ast.ExportStatement:
+		return e.eval(node.Statement, env)
+	case *ast.ImportStatement:
+		return e.evalImportStatement(node, env)
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
 		return &object.Function{Parameters: params, Env: env, Body: body}
 
+	case *ast.MacroLiteral:
+		return newError("macro definitions are only allowed in top-level let statements")
+
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		if isQuoteCall(node) {
+			return e.quote(node, env)
+		}
+		function := e.eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return e.callFunction(function, args, node)
 
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env)
+		index := e.eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return e.evalSliceExpression(node, env)
+	case *ast.MemberExpression:
+		return e.evalMemberExpression(node, env)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.evalHashLiteral(node, env)
 
 	}
 
@@ -107,6 +233,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -129,23 +257,171 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
 		return NULL
 	}
 	return arrayObject.Elements[idx]
 }
 
+// 字符串按字符（rune）取下标，越界返回 null
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx, ok := normalizeIndex(index.(*object.Integer).Value, len(runes))
+	if !ok {
+		return NULL
+	}
+	return &object.String{Value: string(runes[idx])}
+}
+
+// 负数下标从末尾开始计数，越界时返回 false
+func normalizeIndex(idx int64, length int) (int64, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	if idx < 0 || idx >= int64(length) {
+		return 0, false
+	}
+	return idx, true
+}
+
+// 切片求值，支持数组和字符串（按字符）
+func (e *Evaluator) evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := e.eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	bounds := make([]*int64, 3)
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		val := e.eval(exp, env)
+		if isError(val) {
+			return val
+		}
+		integer, ok := val.(*object.Integer)
+		if !ok {
+			return newError("slice index must be INTEGER, got %s", val.Type())
+		}
+		bounds[i] = &integer.Value
+	}
+	step := int64(1)
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return newError("slice step cannot be zero")
+	}
+	switch left := left.(type) {
+	case *object.Array:
+		indices := sliceIndices(int64(len(left.Elements)), bounds[0], bounds[1], step)
+		elements := make([]object.Object, len(indices))
+		for i, idx := range indices {
+			elements[i] = left.Elements[idx]
+		}
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		indices := sliceIndices(int64(len(runes)), bounds[0], bounds[1], step)
+		result := make([]rune, len(indices))
+		for i, idx := range indices {
+			result[i] = runes[idx]
+		}
+		return &object.String{Value: string(result)}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// 按 Python 的规则计算切片选中的下标：负数从末尾计数，越界的边界被截断
+func sliceIndices(length int64, start, end *int64, step int64) []int64 {
+	clamp := func(bound *int64, def, lower, upper int64) int64 {
+		if bound == nil {
+			return def
+		}
+		v := *bound
+		if v < 0 {
+			v += length
+		}
+		if v < lower {
+			return lower
+		}
+		if v > upper {
+			return upper
+		}
+		return v
+	}
+	indices := []int64{}
+	if step > 0 {
+		from := clamp(start, 0, 0, length)
+		to := clamp(end, length, 0, length)
+		for i := from; i < to; i += step {
+			indices = append(indices, i)
+			// 先判断下一个下标是否越界，避免 i + step 溢出
+			if step >= to-i {
+				break
+			}
+		}
+	} else {
+		from := clamp(start, length-1, -1, length-1)
+		to := clamp(end, -1, -1, length-1)
+		for i := from; i > to; i += step {
+			indices = append(indices, i)
+			if step <= to-i {
+				break
+			}
+		}
+	}
+	return indices
+}
+
+// 调用 monkey 函数或内置函数，供宿主程序从 go 代码中回调
+func (e *Evaluator) ApplyFunction(fn object.Object, args ...object.Object) object.Object {
+	return e.ApplyFunctionContext(context.Background(), fn, args...)
+}
+
+// 与 ApplyFunction 相同，ctx 被取消或超时时中止执行
+func (e *Evaluator) ApplyFunctionContext(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	defer e.begin(ctx)()
+	return e.applyFunction(fn, args)
+}
+
 // 执行函数
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
+	return e.callFunction(fn, args, nil)
+}
+
+// 执行函数，call 是调用处的表达式，由内置函数或宿主程序回调时为 nil
+func (e *Evaluator) callFunction(fn object.Object, args []object.Object, call *ast.CallExpression) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if err := e.enterCall(); err != nil {
+			return err
+		}
+		defer e.exitCall()
+		if len(args) < len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d",
+				len(args), len(fn.Parameters))
+		}
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if err := e.allocBytes(envSize(len(args))); err != nil {
+			return err
+		}
+		if e.hooks != nil {
+			e.hooks.EnterFunction(fn, call, extendedEnv)
+		}
+		evaluated := unwrapReturnValue(e.eval(fn.Body, extendedEnv))
+		if e.hooks != nil {
+			e.hooks.ExitFunction(fn, evaluated)
+		}
+		return evaluated
 	case *object.Builtin:
-		return fn.Fn(args...)
+		result := fn.Fn(args...)
+		if err := e.alloc(result); err != nil {
+			return err
+		}
+		return result
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
@@ -172,10 +448,10 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
@@ -186,10 +462,10 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 		if result != nil {
 			rt := result.Type()
 			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
@@ -197,6 +473,10 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 			}
 		}
 	}
+	// 空的块或以 let 等语句结尾的块作为表达式的值是 null
+	if result == nil {
+		return NULL
+	}
 	return result
 }
 
@@ -208,6 +488,8 @@ func evalInfixExpression(
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -252,6 +534,9 @@ func evalIntegerInfixExpression(
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -267,6 +552,56 @@ func evalIntegerInfixExpression(
 	}
 }
 
+// 计算浮点数中缀表达式，整数和浮点数混合运算时整数先转换为浮点数
+func evalFloatInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// 是否整数或浮点数
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.Float:
+		return true
+	}
+	return false
+}
+
+// 整数或浮点数的浮点数值
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -298,6 +633,9 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if f, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -f.Value}
+	}
 	if right.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: -%s", right.Type())
 	}
@@ -305,15 +643,15 @@ func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	return &object.Integer{Value: -value}
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.eval(ie.Alternative, env)
 	} else {
 		return NULL
 	}
@@ -342,7 +680,7 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func evalIdentifier(
+func (e *Evaluator) evalIdentifier(
 	node *ast.Identifier,
 	env *object.Environment,
 ) object.Object {
@@ -350,20 +688,20 @@ func evalIdentifier(
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := e.builtins[node.Value]; ok {
 		return builtin
 	}
 	return newError("identifier not found: " + node.Value)
 }
 
 // 计算一组表达式，返回值列表
-func evalExpressions(
+func (e *Evaluator) evalExpressions(
 	exps []ast.Expression,
 	env *object.Environment,
 ) []object.Object {
 	var result []object.Object
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -372,13 +710,13 @@ func evalExpressions(
 	return result
 }
 
-func evalHashLiteral(
+func (e *Evaluator) evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	hash := object.NewHash()
+	for _, keyNode := range node.OrderedKeys() {
+		key := e.eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
@@ -386,12 +724,11 @@ func evalHashLiteral(
 		if !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
-		value := Eval(valueNode, env)
+		value := e.eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
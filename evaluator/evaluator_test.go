@@ -1,10 +1,19 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"monkey/ast"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -128,6 +137,8 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (true) {}", nil},
+		{"if (true) { let a = 1; }", nil},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -226,6 +237,22 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"fn(a) {}()",
+			"wrong number of arguments. got=0, want=1",
+		},
+		{
+			"let f = fn(a, b) { b }; f(1);",
+			"wrong number of arguments. got=1, want=2",
+		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"if (false) {}.upper",
+			"NULL has no method upper",
+		},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -418,6 +445,14 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
@@ -511,3 +546,1112 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`map([], fn(x) { x })`, "[]"},
+		{`map([1], len)`, "argument to `len` not supported, got INTEGER"},
+		{`map(1, fn(x) { x })`, "argument to `map` must be ARRAY, got INTEGER"},
+		{`map([1], 1)`, "argument to `map` must be FUNCTION, got INTEGER"},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]"},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, 10},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, 16},
+		{`reduce([], fn(acc, x) { acc + x }, 0)`, 0},
+		{`reduce([], fn(acc, x) { acc + x })`, "`reduce` of empty array with no initial value"},
+		{`find([1, 2, 3], fn(x) { x > 1 })`, 2},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, nil},
+		{`any([1, 2, 3], fn(x) { x == 2 })`, true},
+		{`any([], fn(x) { true })`, false},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, true},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, false},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]"},
+		{`sort([1, "a"])`, "cannot compare STRING with INTEGER"},
+		{`sort_by([[2, "b"], [1, "a"], [2, "c"]], fn(x) { x[0] })`, "[[1, a], [2, b], [2, c]]"},
+		{`map([1, 2], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+// 校验求值结果：int/bool 比较值，string 比较错误信息或 Inspect 的结果，nil 表示 NULL
+func testExpectedObject(t *testing.T, input string, evaluated object.Object, expected interface{}) {
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, evaluated, int64(expected))
+	case bool:
+		testBooleanObject(t, evaluated, expected)
+	case string:
+		if errObj, ok := evaluated.(*object.Error); ok {
+			if errObj.Message != expected {
+				t.Errorf("wrong error message for %s. expected=%q, got=%q",
+					input, expected, errObj.Message)
+			}
+			return
+		}
+		if evaluated == nil || evaluated.Inspect() != expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v",
+				input, expected, evaluated)
+		}
+	default:
+		testNullObject(t, evaluated)
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`split("a,b,c", ",")`, "[a, b, c]"},
+		{`split("  a  b c ")`, "[a, b, c]"},
+		{`split(1, ",")`, "argument to `split` must be STRING, got INTEGER"},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join(["a", "b"])`, "ab"},
+		{`join(["a", 1], "-")`, "`join` requires an array of STRING, got INTEGER at index 1"},
+		{`trim("  hi  ")`, "hi"},
+		{`trim_left("  hi  ")`, "hi  "},
+		{`trim_right("  hi  ")`, "  hi"},
+		{`upper("Monkey")`, "MONKEY"},
+		{`lower("Monkey")`, "monkey"},
+		{`contains("monkey", "key")`, true},
+		{`contains("monkey", "dog")`, false},
+		{`starts_with("monkey", "mon")`, true},
+		{`ends_with("monkey", "mon")`, false},
+		{`index_of("monkey", "key")`, 3},
+		{`index_of("monkey", "dog")`, -1},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("a-b-c", "-", "+", 1)`, "a+b-c"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, "negative repeat count: -1"},
+		{`repeat("ab", 4000000000)`, "repeat result too large: 4000000000 * 2 bytes"},
+		{`substr("monkey", 3)`, "key"},
+		{`substr("monkey", 0, 3)`, "mon"},
+		{`substr("monkey", 4, 10)`, "ey"},
+		{`substr("hello", 1, 9223372036854775807)`, "ello"},
+		{`substr("monkey", 7)`, "substr out of range: start=7, length=-1, len=6"},
+		{`format("%s is %d, %t", "monkey", 5, true)`, "monkey is 5, true"},
+		{`format("%v", [1, 2])`, "[1, 2]"},
+		{`format(1)`, "argument to `format` must be STRING, got INTEGER"},
+	}
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("你好，世界")`, 5},
+		{`"你好，世界"[1]`, "好"},
+		{`"abc"[2]`, "c"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[-4]`, nil},
+		{`let 名字 = "猴子"; 名字 + "!"`, "猴子!"},
+		{`chars("猴子a")`, "[猴, 子, a]"},
+		{`bytes("é")`, "[195, 169]"},
+		{`ord("猴")`, 29492},
+		{`ord("ab")`, "argument to `ord` must be a single character, got \"ab\""},
+		{`chr(29492)`, "猴"},
+		{`chr(-1)`, "invalid code point: -1"},
+		{`substr("你好，世界", 3)`, "世界"},
+		{`index_of("你好，世界", "世")`, 3},
+	}
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3, 4, 5][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4, 5][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4, 5][3:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:]", "[1, 2, 3, 4, 5]"},
+		{"[1, 2, 3, 4, 5][-2:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:-2]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4, 5][::2]", "[1, 3, 5]"},
+		{"[1, 2, 3, 4, 5][::-1]", "[5, 4, 3, 2, 1]"},
+		{"[1, 2, 3, 4, 5][3:0:-1]", "[4, 3, 2]"},
+		{"[1, 2, 3, 4, 5][-1:-4:-2]", "[5, 3]"},
+		{"[1, 2, 3][5:10]", "[]"},
+		{"[1, 2, 3][2:1]", "[]"},
+		{"[1, 2, 3][-10:10]", "[1, 2, 3]"},
+		{`"monkey"[1:3]`, "on"},
+		{`"你好，世界"[3:]`, "世界"},
+		{`"abc"[::-1]`, "cba"},
+		{"[1, 2, 3][1::9223372036854775807]", "[2]"},
+		{`"abc"[2::9223372036854775807]`, "c"},
+		{"[1, 2, 3][1::-9223372036854775807 - 1]", "[2]"},
+		{"[1, 2, 3][::0]", "slice step cannot be zero"},
+		{`[1, 2, 3]["a":]`, "slice index must be INTEGER, got STRING"},
+		{"5[1:2]", "slice operator not supported: INTEGER"},
+	}
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type(true)`, "BOOLEAN"},
+		{`type([])`, "ARRAY"},
+		{`type({})`, "HASH"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`type(if (false) { 1 })`, "NULL"},
+		{`str(12) + "3"`, "123"},
+		{`str([1, "a"])`, "[1, a]"},
+		{`str("a")`, "a"},
+		{`str({"to_string": fn(self) { "custom" }})`, "custom"},
+		{`str({"to_string": fn(self) { 1 }})`, "`to_string` must return STRING, got INTEGER"},
+		{`let p = {"x": 1, "to_string": fn(self) { "P(" + str(self["x"]) + ")" }}; format("%s|%v", p, p)`, "P(1)|P(1)"},
+		{`puts({"to_string": fn(self) { 1 }})`, "`to_string` must return STRING, got INTEGER"},
+		{`int("42") + 1`, 43},
+		{`int(" -7 ")`, -7},
+		{`int("abc")`, "could not parse \"abc\" as integer"},
+		{`int(true)`, 1},
+		{`int([])`, "cannot convert ARRAY to INTEGER"},
+		{`bool(0)`, true},
+		{`bool(if (false) { 1 })`, false},
+		{`is_integer(1)`, true},
+		{`is_integer("1")`, false},
+		{`is_string("1")`, true},
+		{`is_bool(false)`, true},
+		{`is_array([])`, true},
+		{`is_hash({})`, true},
+		{`is_null(if (false) { 1 })`, true},
+		{`is_function(len)`, true},
+		{`is_function(fn() {})`, true},
+		{`is_function(1)`, false},
+	}
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssertBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`assert(1 < 2)`, nil},
+		{`assert(1 > 2)`, "assertion failed"},
+		{`assert(false, "one is not greater than two")`, "assertion failed: one is not greater than two"},
+		{`assert(if (false) { 1 }, 42)`, "assertion failed: 42"},
+		{`assert_eq(1 + 1, 2)`, nil},
+		{`assert_eq([1, [2, "a"]], [1, [2, "a"]])`, nil},
+		{`assert_eq({"a": 1, "b": [2]}, {"b": [2], "a": 1})`, nil},
+		{`assert_eq(len, len)`, nil},
+		{`assert_eq(1 + 1, 3)`, "assertion failed: values are not equal\n--- expected\n+++ actual\n-3\n+2"},
+		{`assert_eq(1, "1", "wrong type")`, "assertion failed: wrong type\n--- expected\n+++ actual\n-\"1\"\n+1"},
+		{`assert_eq(1, 1.0)`, nil},
+		{`assert_eq([1, {"a": 2}], [1.0, {"a": 2.0}])`, nil},
+		{`assert_eq(1, 1.5)`, "assertion failed: values are not equal\n--- expected\n+++ actual\n-1.5\n+1"},
+		{`assert_eq({"a": 1}, {"a": 2})`, "assertion failed: values are not equal\n--- expected\n+++ actual\n-{a: 2}\n+{a: 1}"},
+		{`assert_eq(fn() {}, fn() {})`, "assertion failed: values are not equal\n--- expected\n+++ actual\n fn() {\n \n }"},
+		{"assert_eq(\"a\nb\nc\nd\", \"a\nc\nx\nd\")", "assertion failed: values are not equal\n--- expected\n+++ actual\n \"a\n+b\n c\n-x\n d\""},
+		{`let f = fn() { assert(false); 1 }; f()`, "assertion failed"},
+	}
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestImportStatements(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"math.mk": `
+			import "lib/helpers.mk" as h;
+			export let add = fn(a, b) { a + b };
+			export let double = fn(x) { h.twice(x) };
+			let secret = 42;
+		`,
+		"lib/helpers.mk": `export let twice = fn(x) { x * 2 };`,
+		"cycle_a.mk":     `import "cycle_b.mk" as b;`,
+		"cycle_b.mk":     `import "cycle_a.mk" as a;`,
+		"broken.mk":      `let 1;`,
+		"failing.mk":     `export let x = 1 + true;`,
+		"returning.mk":   `export let a = 1; return 5; export let b = 2;`,
+		"lib/escape.mk":  `import "../../outside.mk" as o;`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "math.mk" as m; m.add(1, 2)`, 3},
+		{`import "math.mk" as m; m.double(21)`, 42},
+		{`import "math.mk"; math.add(2, 2)`, 4},
+		{`import { add, double } from "math.mk"; add(double(1), 1)`, 3},
+		{`import "math.mk" as m; m.secret`, `module "math.mk" has no exported member secret`},
+		{`import { secret } from "math.mk";`, `module "math.mk" has no exported member secret`},
+		{`import { nothing } from "lib/helpers.mk";`, `module "helpers.mk" has no exported member nothing`},
+		{`import "missing.mk" as m;`, "cannot import \"" + filepath.Join(dir, "missing.mk") +
+			"\": open " + filepath.Join(dir, "missing.mk") + ": no such file or directory"},
+		{`import "cycle_a.mk" as a;`, "import cycle detected: cycle_a.mk -> cycle_b.mk -> cycle_a.mk"},
+		{`import "broken.mk" as b;`, "parse errors in " + filepath.Join(dir, "broken.mk") +
+			": expected next token to be IDENT, got INT instead"},
+		{`import "failing.mk" as f;`, "type mismatch: INTEGER + BOOLEAN"},
+		{`import "returning.mk" as r; r.b`, `module "returning.mk" returned before exporting b`},
+		{`import { b } from "returning.mk"; type(b)`, `module "returning.mk" returned before exporting b`},
+		{`let x = 1; x.y`, "INTEGER has no method y"},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		env := object.NewEnvironment()
+		env.SetDir(dir)
+		testExpectedObject(t, tt.input, Eval(program, env), tt.expected)
+	}
+
+	// 同一个模块只加载一次
+	p := parser.New(lexer.New(`import "math.mk" as a; import "math.mk" as b; a == b`))
+	env := object.NewEnvironment()
+	env.SetDir(dir)
+	testBooleanObject(t, Eval(p.ParseProgram(), env), true)
+
+	// 脚本目录以外的模块需要 import 能力，被拒绝时不会读取文件
+	denied := []struct {
+		input string
+		path  string
+	}{
+		{`import "/etc/passwd";`, "/etc/passwd"},
+		{`import "../outside.mk";`, "../outside.mk"},
+		{`import "lib/escape.mk";`, "../../outside.mk"},
+	}
+	for _, tt := range denied {
+		env = object.NewEnvironment()
+		env.SetDir(dir)
+		testExpectedObject(t, tt.input, Eval(testParseProgram(tt.input), env),
+			"capability denied: importing \""+tt.path+"\" from outside the script directory requires import")
+		e := New()
+		e.SetCapabilities(CapImport)
+		if result := e.Eval(testParseProgram(tt.input), env); !strings.Contains(result.Inspect(), "cannot import") &&
+			!strings.Contains(result.Inspect(), "parse errors") {
+			t.Errorf("%s: expected the import capability to allow loading. got=%s", tt.input, result.Inspect())
+		}
+	}
+}
+
+func TestMemberExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let h = {"name": "monkey", "age": 5}; h.age`, 5},
+		{`let h = {"name": "monkey"}; h.name.upper()`, "MONKEY"},
+		{`{"a": 1}.b`, nil},
+		{`let h = {"add": fn(a, b) { a + b }}; h.add(1, 2)`, 3},
+		{`{"a": {"b": 2}}.a.b`, 2},
+		{`"abc".upper()`, "ABC"},
+		{`" a,b ".trim().split(",")`, "[a, b]"},
+		{`"你好".len()`, 2},
+		{`[3, 1, 2].sort().map(fn(x) { x * 10 })`, "[10, 20, 30]"},
+		{`[1, 2, 3].filter(fn(x) { x > 1 }).len()`, 2},
+		{`[1, 2, 3].reduce(fn(a, b) { a + b }, 0)`, 6},
+		{`["a", "b"].join("-")`, "a-b"},
+		{`let up = "abc".upper; up()`, "ABC"},
+		{`"abc".foo()`, "STRING has no method foo"},
+		{`[1].upper()`, "ARRAY has no method upper"},
+		{`5.len()`, "INTEGER has no method len"},
+		{`"abc".repeat("x")`, "argument to `repeat` must be INTEGER, got STRING"},
+	}
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEvaluatorBuiltins(t *testing.T) {
+	program := parser.New(lexer.New(`[1, 2].map(fn(x) { twice(x) })`)).ParseProgram()
+
+	e := New()
+	e.RegisterBuiltin("twice", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	testExpectedObject(t, "twice", e.Eval(program, object.NewEnvironment()), "[2, 4]")
+
+	// 其他求值器不受影响
+	testExpectedObject(t, "twice", New().Eval(program, object.NewEnvironment()),
+		"identifier not found: twice")
+
+	e.RemoveBuiltin("puts")
+	e.RemoveBuiltin("map")
+	if _, ok := e.Builtin("puts"); ok {
+		t.Errorf("puts was not removed")
+	}
+	testExpectedObject(t, "puts", e.Eval(parser.New(lexer.New(`puts(1)`)).ParseProgram(),
+		object.NewEnvironment()), "identifier not found: puts")
+	// 移除的内置函数也不能作为方法调用
+	testExpectedObject(t, "map", e.Eval(program, object.NewEnvironment()),
+		"ARRAY has no method map")
+}
+
+func TestConcurrentEvaluators(t *testing.T) {
+	input := `
+	let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+	[10, 11, 12].map(fib).reduce(fn(a, b) { a + b })`
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e := New()
+			e.RegisterBuiltin("id", func(args ...object.Object) object.Object {
+				return &object.Integer{Value: int64(i)}
+			})
+			program := parser.New(lexer.New(input + " + id()")).ParseProgram()
+			testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 55+89+144+int64(i))
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestOutputBuiltins(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedStdout string
+		expectedStderr string
+	}{
+		{`puts("a", 1, [true])`, "a\n1\n[true]\n", ""},
+		{`print("a", 1); print("b")`, "a1b", ""},
+		{`printf("%s=%d;", "x", 5)`, "x=5;", ""},
+		{`eprint("oops")`, "", "oops"},
+		{`puts("out"); eprint("err"); print("!")`, "out\n!", "err"},
+		{`let p = {"to_string": fn(self) { "point" }}; puts(p); print(p); eprint(p); printf("%s", p)`, "point\npointpoint", "point"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		e := New()
+		e.SetStdout(&stdout)
+		e.SetStderr(&stderr)
+		e.Eval(parser.New(lexer.New(tt.input)).ParseProgram(), object.NewEnvironment())
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%s: wrong stdout. expected=%q, got=%q", tt.input, tt.expectedStdout, stdout.String())
+		}
+		if stderr.String() != tt.expectedStderr {
+			t.Errorf("%s: wrong stderr. expected=%q, got=%q", tt.input, tt.expectedStderr, stderr.String())
+		}
+	}
+	testExpectedObject(t, "printf", testEval(`printf(1)`),
+		"argument to `printf` must be STRING, got INTEGER")
+}
+
+func TestExecutionLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected string
+	}{
+		{`let f = fn() { f() }; f()`, Limits{MaxDepth: 100}, "maximum call depth exceeded (max 100)"},
+		{`let f = fn() { f() }; f()`, Limits{MaxSteps: 1000}, "step budget exceeded (max 1000)"},
+		{`let f = fn(s) { f(s + s) }; f("ab")`, Limits{MaxMemory: 1 << 20}, "memory budget exceeded (max 1048576 bytes)"},
+		{`map([1, 2, 3], fn(x) { x * 2 })`, Limits{MaxSteps: 3}, "step budget exceeded (max 3)"},
+	}
+	for _, tt := range tests {
+		e := New()
+		e.SetLimits(tt.limits)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		testExpectedObject(t, tt.input, e.Eval(program, object.NewEnvironment()), tt.expected)
+	}
+
+	// 默认限制调用深度，无限递归不会耗尽 go 的栈
+	testExpectedObject(t, "recursion", testEval(`let f = fn() { f() }; f()`),
+		"maximum call depth exceeded (max 10000)")
+	// 每层调用中嵌套很深的表达式叠加起来也不会耗尽栈
+	deep := "let f = fn() { " + strings.Repeat("!", 9000) + "f() }; f()"
+	testExpectedObject(t, "deep recursion", testEval(deep),
+		"maximum nesting depth exceeded (max 200000)")
+
+	// 预算在每次执行时重新计算
+	e := New()
+	e.SetLimits(Limits{MaxSteps: 50})
+	program := parser.New(lexer.New(`1 + 2 + 3`)).ParseProgram()
+	for i := 0; i < 10; i++ {
+		testIntegerObject(t, e.Eval(program, object.NewEnvironment()), 6)
+	}
+}
+
+func TestEvalContextCancellation(t *testing.T) {
+	e := New()
+	e.SetLimits(Limits{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	// 没有步数限制时只能靠 ctx 中止：每层调用两次，总调用次数是 2^25
+	input := `let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) + f(n - 1) } }; f(25)`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	start := time.Now()
+	result := e.EvalContext(ctx, program, object.NewEnvironment())
+	testExpectedObject(t, input, result, "execution cancelled: context deadline exceeded")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancellation took too long: %s", elapsed)
+	}
+
+	// 已取消的 ctx 不执行任何代码
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	var out bytes.Buffer
+	e.SetStdout(&out)
+	program = parser.New(lexer.New(`puts("x")`)).ParseProgram()
+	testExpectedObject(t, "cancelled", e.EvalContext(ctx, program, object.NewEnvironment()),
+		"execution cancelled: context canceled")
+	if out.Len() != 0 {
+		t.Errorf("code ran after cancellation: %q", out.String())
+	}
+}
+
+// 结果可能比参数大得多的内置函数在分配结果之前检查内存预算
+func TestResultSizeLimits(t *testing.T) {
+	dir := t.TempDir()
+	big := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(big, bytes.Repeat([]byte("a"), 2<<20), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	exceeded := "memory budget exceeded (max 1048576 bytes)"
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let s = repeat("a", 20000); replace(s, "", s)`, exceeded},
+		{`let s = repeat("a", 100000); replace(s, "", s)`, exceeded},
+		{`replace(repeat("a", 1000), "a", repeat("b", 2000), 100)`, 200000 + 900},
+		{`join(split(repeat("a,", 1000), ","), repeat("-", 2000))`, exceeded},
+		{`let s = repeat("a", 400000); format("%s%s%s", s, s, s)`, exceeded},
+		{`format("%s", repeat("a", 400000))`, 400000},
+		{`split(repeat("a", 100000), "")`, exceeded},
+		{`chars(repeat("a", 50000))`, exceeded},
+		{`bytes(repeat("a", 50000))`, exceeded},
+		{`json_stringify(split(repeat("a", 1000), ""), repeat(" ", 2000))`, exceeded},
+		{`read_file("` + big + `")`, exceeded},
+		{`read_file("/dev/zero")`, exceeded},
+		{`exec("sh", "-c", "yes | head -c 2000000")`, exceeded},
+	}
+	for _, tt := range tests {
+		e := New()
+		e.SetLimits(Limits{MaxMemory: 1 << 20})
+		e.SetCapabilities(CapFSRead | CapExec)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		result := e.Eval(program, object.NewEnvironment())
+		if n, ok := tt.expected.(int); ok {
+			// 没有超出预算时只检查结果的长度
+			if str, ok := result.(*object.String); !ok || len(str.Value) != n {
+				t.Errorf("%s: expected a string of %d bytes, got %.40s", tt.input, n, result.Inspect())
+			}
+			continue
+		}
+		testExpectedObject(t, tt.input, result, tt.expected)
+	}
+
+	// 没有设置内存预算时结果的大小也有上限
+	testExpectedObject(t, "unlimited", testEval(`let s = repeat("a", 100000); replace(s, "", s)`),
+		"`replace` result too large: 10000200000 bytes")
+}
+
+func TestCapabilities(t *testing.T) {
+	t.Setenv("MONKEY_TEST_VAR", "banana")
+	tests := []struct {
+		input    string
+		caps     Capability
+		expected interface{}
+	}{
+		{`getenv("MONKEY_TEST_VAR")`, CapNone, "capability denied: `getenv` requires env"},
+		{`getenv("MONKEY_TEST_VAR")`, CapEnv, "banana"},
+		{`getenv("MONKEY_TEST_MISSING")`, CapEnv, nil},
+		{`getenv("MONKEY_TEST_VAR")`, CapAll, "banana"},
+		{`time() > 0`, CapFSRead, "capability denied: `time` requires clock"},
+		{`time() > 0`, CapClock, true},
+		{`time_ms() > time()`, CapClock, true},
+		{`random(10)`, CapNone, "capability denied: `random` requires random"},
+		{`let r = random(10); if (r < 0) { false } else { r < 10 }`, CapRandom, true},
+		{`random(0)`, CapRandom, "argument to `random` must be positive, got 0"},
+		{`exec("echo", "hi")`, CapEnv, "capability denied: `exec` requires exec"},
+		{`exec("sh", "-c", "echo hi; exit 3")["stdout"]`, CapExec, "hi\n"},
+		{`exec("sh", "-c", "exit 3")["code"]`, CapExec, 3},
+		{`import "missing.mk";`, CapFSRead,
+			"capability denied: importing \"missing.mk\" from outside the script directory requires import"},
+	}
+	for _, tt := range tests {
+		e := New()
+		e.SetCapabilities(tt.caps)
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		testExpectedObject(t, tt.input, e.Eval(program, object.NewEnvironment()), tt.expected)
+	}
+
+	// 默认不授予任何能力
+	testExpectedObject(t, "default", testEval(`getenv("HOME")`),
+		"capability denied: `getenv` requires env")
+}
+
+// SetCapabilities 不改动宿主移除或替换过的内置函数，与调用的先后顺序无关
+func TestSetCapabilitiesKeepsHostBuiltins(t *testing.T) {
+	e := New()
+	e.RemoveBuiltin("exec")
+	e.RegisterBuiltin("time", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 42}
+	})
+	e.SetCapabilities(CapAll)
+	if _, ok := e.Builtin("exec"); ok {
+		t.Errorf("SetCapabilities restored a removed builtin")
+	}
+	testExpectedObject(t, "time", e.Eval(testParseProgram("time()"), object.NewEnvironment()), 42)
+	e.SetCapabilities(CapNone)
+	testExpectedObject(t, "time", e.Eval(testParseProgram("time()"), object.NewEnvironment()), 42)
+	// 其他内置函数照常切换
+	testExpectedObject(t, "getenv", e.Eval(testParseProgram(`getenv("HOME")`), object.NewEnvironment()),
+		"capability denied: `getenv` requires env")
+}
+
+func TestParseCapability(t *testing.T) {
+	for _, name := range CapabilityNames() {
+		cap, err := ParseCapability(name)
+		if err != nil {
+			t.Fatalf("ParseCapability(%q) error: %s", name, err)
+		}
+		if cap.String() != name {
+			t.Errorf("round trip of %q. got=%q", name, cap.String())
+		}
+	}
+	if cap, _ := ParseCapability("all"); cap != CapAll {
+		t.Errorf("all is not CapAll. got=%s", cap)
+	}
+	if _, err := ParseCapability("network"); err == nil {
+		t.Errorf("expected error for unknown capability")
+	}
+	if (CapEnv | CapClock).String() != "env,clock" {
+		t.Errorf("wrong String. got=%q", (CapEnv | CapClock).String())
+	}
+}
+
+func TestFileSystemBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("hello"), 0o644)
+	os.Mkdir(filepath.Join(dir, "a"), 0o755)
+	missing := filepath.Join(dir, "missing.txt")
+
+	e := New()
+	e.SetCapabilities(CapFSRead | CapFSWrite)
+	env := object.NewEnvironment()
+	env.Set("dir", &object.String{Value: dir})
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`read_file(path_join(dir, "b.txt"))`, "hello"},
+		{`read_file(path_join(dir, "missing.txt"))`, "read_file: open " + missing + ": no such file or directory"},
+		{`read_file(1)`, "argument to `read_file` must be STRING, got INTEGER"},
+		{`write_file(path_join(dir, "c.txt"), "new"); read_file(path_join(dir, "c.txt"))`, "new"},
+		{`write_file(path_join(dir, "nope", "c.txt"), "x")`, "write_file: open " +
+			filepath.Join(dir, "nope", "c.txt") + ": no such file or directory"},
+		{`list_dir(dir)`, "[a, b.txt, c.txt]"},
+		{`list_dir(path_join(dir, "missing.txt"))`, "list_dir: open " + missing + ": no such file or directory"},
+		{`exists(path_join(dir, "a"))`, true},
+		{`exists(path_join(dir, "missing.txt"))`, false},
+		{`path_join("a", "b", "../c.txt")`, filepath.Join("a", "c.txt")},
+		{`path_base("/x/y/z.mk")`, "z.mk"},
+		{`path_dir("/x/y/z.mk")`, "/x/y"},
+		{`path_ext("/x/y/z.mk")`, ".mk"},
+	}
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		testExpectedObject(t, tt.input, e.Eval(program, env), tt.expected)
+	}
+
+	// 只读时不能写
+	e.SetCapabilities(CapFSRead)
+	program := parser.New(lexer.New(`write_file(path_join(dir, "d.txt"), "x")`)).ParseProgram()
+	testExpectedObject(t, "write_file", e.Eval(program, env),
+		"capability denied: `write_file` requires fs-write")
+	if _, err := os.Stat(filepath.Join(dir, "d.txt")); err == nil {
+		t.Errorf("file written without fs-write capability")
+	}
+	// 路径处理不需要能力
+	program = parser.New(lexer.New(`path_base("/x/y.mk")`)).ParseProgram()
+	testExpectedObject(t, "path_base", New().Eval(program, env), "y.mk")
+}
+
+func TestFloats(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1.5", "1.5"},
+		{"1.5 + 1.5", "3.0"},
+		{"1 + 0.5", "1.5"},
+		{"10 / 4.0", "2.5"},
+		{"-2.5", "-2.5"},
+		{"0.1 * 3 > 0.3", true},
+		{"2.0 == 2", true},
+		{"1.5 < 1", false},
+		{"1.0 / 0", "+Inf"},
+		{"type(1.5)", "FLOAT"},
+		{"is_float(1.5)", true},
+		{"is_float(1)", false},
+		{"int(2.9)", 2},
+		{"int(-2.9)", -2},
+		{"int(1.0 / 0)", "cannot convert +Inf to INTEGER"},
+		{"int(10000000000000000000.0)", "cannot convert 1e+19 to INTEGER"},
+		{"int(-9223372036854775808.0)", -9223372036854775808},
+		{"int(-10000000000000000000.0)", "cannot convert -1e+19 to INTEGER"},
+		{"int(9223372036854775807.0)", "cannot convert 9.223372036854776e+18 to INTEGER"},
+		{"float(2)", "2.0"},
+		{`float(" 2.25 ")`, "2.25"},
+		{`float("x")`, `could not parse "x" as float`},
+		{"sort([2, 1.5, 3, 0.5])", "[0.5, 1.5, 2, 3]"},
+		{`format("%.2f", 1.5)`, "1.50"},
+		{`1.5 + "x"`, "type mismatch: FLOAT + STRING"},
+		{`{1.5: 1}`, "unusable as hash key: FLOAT"},
+		{`json_parse(json_stringify({"ratio": 0.25}))["ratio"] == 0.25`, true},
+		{`json_parse(json_stringify([1.5, 2.0]))`, "[1.5, 2.0]"},
+	}
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"c": 1, "a": 2, "b": 3}`, "{c: 1, a: 2, b: 3}"},
+		{`{3: "x", 1: "y", true: "z", 2: "w"}`, "{3: x, 1: y, true: z, 2: w}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+	}
+	for _, tt := range tests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestJSONBuiltins(t *testing.T) {
+	// 字符串字面量不支持转义，JSON 文本通过环境变量传入
+	docs := map[string]string{
+		"doc":       `{"b": 1, "a": [true, null, 1.5, "s"], "c": {}}`,
+		"nested":    `{"x": [1, {"y": "z"}], "w": null, "v": [], "u": "<\"é\">"}`,
+		"sci":       `1e3`,
+		"big":       `99999999999999999999`,
+		"truncated": `[1, 2`,
+		"trailing":  `[1, 2] 3`,
+		"badkey":    `{1: 2}`,
+	}
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_parse(doc)`, "{b: 1, a: [true, null, 1.5, s], c: {}}"},
+		{`json_parse(doc)["a"][2]`, "1.5"},
+		{`json_parse(sci)`, "1000.0"},
+		{`json_parse("-7")`, -7},
+		{`json_parse(big)`, "1e+20"},
+		{`json_parse("null")`, nil},
+		{`json_parse(truncated)`, "json_parse: unexpected end of JSON input"},
+		{`json_parse(trailing)`, "json_parse: unexpected data after top-level value"},
+		{`json_parse(badkey)`, "json_parse: object member name must be a string"},
+		{`json_parse(1)`, "argument to `json_parse` must be STRING, got INTEGER"},
+		{`json_stringify(json_parse(doc))`, `{"b":1,"a":[true,null,1.5,"s"],"c":{}}`},
+		{`json_stringify(json_parse(nested))`, `{"x":[1,{"y":"z"}],"w":null,"v":[],"u":"<\"é\">"}`},
+		{`json_stringify(json_parse(nested), 2)`, `{
+  "x": [
+    1,
+    {
+      "y": "z"
+    }
+  ],
+  "w": null,
+  "v": [],
+  "u": "<\"é\">"
+}`},
+		{`json_stringify([1, {}], "> ")`, "[\n> 1,\n> {}\n]"},
+		{`json_stringify(2.0)`, "2.0"},
+		{`json_stringify({1: 2})`, "json_stringify: hash key must be STRING, got INTEGER (1)"},
+		{`json_stringify([fn(x) { x }])`, "json_stringify: cannot encode FUNCTION"},
+		{`json_stringify({"f": len})`, "json_stringify: cannot encode BUILTIN"},
+		{`json_stringify(1.0 / 0)`, "json_stringify: cannot encode +Inf"},
+		{`json_stringify(1, [])`, "argument to `json_stringify` must be INTEGER or STRING, got ARRAY"},
+		{`json_stringify(1, 11)`, "json_stringify: indent must be between 0 and 10, got 11"},
+		{`json_stringify(1, 2, 3)`, "wrong number of arguments. got=3, want=1 or 2"},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		for name, doc := range docs {
+			env.Set(name, &object.String{Value: doc})
+		}
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		testExpectedObject(t, tt.input, Eval(program, env), tt.expected)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Fatalf("expected *object.Quote. got=%T (%+v)", evaluated, evaluated)
+		}
+		if quote.Node == nil {
+			t.Fatalf("quote.Node is nil")
+		}
+		if quote.Node.String() != tt.expected {
+			t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), tt.expected)
+		}
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfixExpression = quote(4 + 4);
+		  quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+		{`quote(unquote(1.5))`, `1.5`},
+		{`quote(unquote("s"))`, `s`},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Fatalf("expected *object.Quote. got=%T (%+v)", evaluated, evaluated)
+		}
+		if quote.Node.String() != tt.expected {
+			t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), tt.expected)
+		}
+	}
+
+	// 同一个 quote 多次求值时，每次使用当时的 unquote 结果
+	input := `let f = fn(x) { quote(unquote(x) + 1) }; [f(1), f(2)]`
+	testExpectedObject(t, input, testEval(input), "[QUOTE((1 + 1)), QUOTE((2 + 1))]")
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(1, 2)`, "wrong number of arguments to `quote`. got=2, want=1"},
+		{`quote(unquote(1, 2))`, "wrong number of arguments to `unquote`. got=2, want=1"},
+		{`quote(unquote([1]))`, "cannot unquote ARRAY"},
+		{`quote(unquote(x))`, "identifier not found: x"},
+		{`unquote(1)`, "identifier not found: unquote"},
+	}
+	for _, tt := range errorTests {
+		testExpectedObject(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Wrong number of statements. got=%d",
+			len(program.Statements))
+	}
+	_, ok := env.Get("number")
+	if ok {
+		t.Fatalf("number should not be defined")
+	}
+	_, ok = env.Get("function")
+	if ok {
+		t.Fatalf("function should not be defined")
+	}
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Wrong number of macro parameters. got=%d",
+			len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", macro.Parameters[0])
+	}
+	if macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameter is not 'y'. got=%q", macro.Parameters[1])
+	}
+	expectedBody := "(x + y)"
+	if macro.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+			let infixExpression = macro() { quote(1 + 2); };
+			infixExpression();
+			`,
+			`(1 + 2)`,
+		},
+		{
+			`
+			let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+			reverse(2 + 2, 10 - 5);
+			`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));
+			`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`
+			let double = macro(x) { quote(unquote(x) * 2); };
+			puts(double(1 + 2));
+			`,
+			`puts(((1 + 2) * 2))`,
+		},
+		{
+			`
+			let early = macro(x) { return quote(unquote(x) * 2); };
+			early(3);
+			`,
+			`(3 * 2)`,
+		},
+	}
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("ExpandMacros returned error: %s", err)
+		}
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q",
+				expected.String(), expanded.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = macro(a) { quote(a) }; m(1, 2)`, "wrong number of arguments to macro `m`. got=2, want=1"},
+		{`let m = macro() { 1 }; m()`, "macro `m` must return QUOTE, got INTEGER"},
+		{`let m = macro() { }; m()`, "macro `m` must return QUOTE, got NULL"},
+		{`let m = macro() { nope }; m()`, "identifier not found: nope"},
+	}
+	for _, tt := range errorTests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	input := `let f = fn() { let m = macro() { quote(1) }; }; f()`
+	testExpectedObject(t, input, testEval(input),
+		"macro definitions are only allowed in top-level let statements")
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+// 参数个数表必须和内置函数的实现一致
+func TestBuiltinArities(t *testing.T) {
+	e := New()
+	e.SetCapabilities(CapAll)
+	names := BuiltinNames()
+	if len(names) != len(builtinArities) {
+		t.Errorf("arity table has %d entries, there are %d builtins", len(builtinArities), len(names))
+	}
+	args := func(n int) []object.Object {
+		objs := make([]object.Object, n)
+		for i := range objs {
+			objs[i] = NULL
+		}
+		return objs
+	}
+	for _, name := range names {
+		arity, ok := BuiltinArity(name)
+		if !ok {
+			t.Errorf("no arity for builtin %s", name)
+			continue
+		}
+		builtin, _ := e.Builtin(name)
+		if arity.Min > 0 {
+			result := builtin.Fn(args(arity.Min - 1)...)
+			if errObj, ok := result.(*object.Error); !ok || !strings.HasPrefix(errObj.Message, "wrong number of arguments") {
+				t.Errorf("%s accepted %d arguments: %v", name, arity.Min-1, result)
+			}
+		}
+		if arity.Max >= 0 {
+			result := builtin.Fn(args(arity.Max + 1)...)
+			if errObj, ok := result.(*object.Error); !ok || !strings.HasPrefix(errObj.Message, "wrong number of arguments") {
+				t.Errorf("%s accepted %d arguments: %v", name, arity.Max+1, result)
+			}
+		}
+	}
+}
+
+// 记录所有事件的钩子
+type recordingHooks struct {
+	events []string
+	stopAt string // 求值到 String() 等于它的节点时中止
+}
+
+func (h *recordingHooks) LoadProgram(program *ast.Program, path string) {
+	h.events = append(h.events, "load "+filepath.Base(path))
+}
+
+func (h *recordingHooks) BeforeEval(node ast.Node, env *object.Environment) error {
+	if _, ok := node.(*ast.BlockStatement); ok {
+		return nil
+	}
+	if _, ok := node.(ast.Statement); ok {
+		line, column := ast.Pos(node)
+		h.events = append(h.events, fmt.Sprintf("%d:%d %s", line, column, node.String()))
+	}
+	if h.stopAt != "" && node.String() == h.stopAt {
+		return fmt.Errorf("stopped at %s", node.String())
+	}
+	return nil
+}
+
+func (h *recordingHooks) EnterFunction(fn *object.Function, call *ast.CallExpression, env *object.Environment) {
+	name := "<callback>"
+	if call != nil {
+		name = call.Function.String()
+	}
+	h.events = append(h.events, fmt.Sprintf("enter %s %v", name, env.Names()))
+}
+
+func (h *recordingHooks) ExitFunction(fn *object.Function, result object.Object) {
+	h.events = append(h.events, "exit "+result.Inspect())
+}
+
+func TestHooks(t *testing.T) {
+	input := `let double = fn(x) { x * 2 };
+let y = double(3);
+map([1], double);`
+	h := &recordingHooks{}
+	e := New()
+	e.SetHooks(h)
+	e.Eval(testParseProgram(input), object.NewEnvironment())
+
+	expected := []string{
+		"1:1 let double = fn(x) (x * 2);",
+		"2:1 let y = double(3);",
+		"enter double [x]",
+		"1:22 (x * 2)",
+		"exit 6",
+		"3:1 map([1], double)",
+		"enter <callback> [x]",
+		"1:22 (x * 2)",
+		"exit 2",
+	}
+	if strings.Join(h.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong events.\ngot:\n%s\nwant:\n%s", strings.Join(h.events, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestHooksAbort(t *testing.T) {
+	h := &recordingHooks{stopAt: "puts(2)"}
+	e := New()
+	var out bytes.Buffer
+	e.SetStdout(&out)
+	e.SetHooks(h)
+	result := e.Eval(testParseProgram("puts(1); puts(2); puts(3);"), object.NewEnvironment())
+	errObj, ok := result.(*object.Error)
+	if !ok || errObj.Message != "stopped at puts(2)" {
+		t.Fatalf("expected error from hook, got %v", result)
+	}
+	if out.String() != "1\n" {
+		t.Errorf("wrong output %q", out.String())
+	}
+}
+
+func TestHooksLoadModule(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lib.mk"), []byte("export let v = 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := &recordingHooks{}
+	e := New()
+	e.SetHooks(h)
+	env := object.NewEnvironment()
+	env.SetDir(dir)
+	e.Eval(testParseProgram(`import "lib.mk";`), env)
+	expected := []string{
+		`1:1 import "lib.mk";`,
+		"load lib.mk",
+		"1:1 export let v = 1;",
+		"1:8 let v = 1;",
+	}
+	if strings.Join(h.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong events.\ngot:\n%s\nwant:\n%s", strings.Join(h.events, "\n"), strings.Join(expected, "\n"))
+	}
+}
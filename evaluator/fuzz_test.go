@@ -0,0 +1,49 @@
+package evaluator
+
+import (
+	"io"
+	"monkey/internal/fuzzseed"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+// 任何能解析的程序都不能让求值 panic。执行受步数、内存和调用深度限制，不授予任何能力
+func FuzzEval(f *testing.F) {
+	seeds, err := fuzzseed.Load(".", "../conformance/testdata")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Add("fn(a){}()")
+	f.Add("1 / 0")
+	f.Add("if (false) {}.upper")
+	f.Add("[1,2,3][1::9223372036854775807]")
+	f.Add(`"abc"[2::9223372036854775807]`)
+	f.Add(`puts(substr("hello", 1, 9223372036854775807))`)
+	f.Add(`"ab"[-9223372036854775807 - 1::-9223372036854775807 - 1]`)
+	f.Add(`repeat("ab", 9223372036854775807)`)
+	f.Add(`replace("aaa", "", "b", 9223372036854775807)`)
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return
+		}
+		e := New()
+		e.SetStdout(io.Discard)
+		e.SetStderr(io.Discard)
+		e.SetLimits(Limits{MaxSteps: 20000, MaxMemory: 1 << 20, MaxDepth: 200})
+		env := object.NewEnvironment()
+		macros := object.NewEnvironment()
+		DefineMacros(program, macros)
+		expanded, err := e.ExpandMacros(program, macros)
+		if err != nil {
+			return
+		}
+		e.Eval(expanded, env)
+	})
+}
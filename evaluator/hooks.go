@@ -0,0 +1,50 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// 求值过程中的钩子，供调试器、性能分析和覆盖率统计等工具使用。
+// 钩子在求值的 goroutine 中同步调用，调试器可以在其中阻塞以暂停执行
+type Hooks interface {
+	// 求值一段代码之前调用。path 是代码文件的绝对路径，不是来自文件的代码为空字符串。
+	// 语法树是宏展开之后的，之后求值的节点都来自这里
+	LoadProgram(program *ast.Program, path string)
+
+	// 求值每个语句和表达式之前调用，返回错误时中止执行，错误信息作为执行的结果
+	BeforeEval(node ast.Node, env *object.Environment) error
+
+	// 调用 monkey 函数时，在求值函数体之前调用。
+	// call 是调用处的表达式，函数由内置函数（如 map）或宿主程序回调时为 nil；env 是函数体的环境
+	EnterFunction(fn *object.Function, call *ast.CallExpression, env *object.Environment)
+
+	// 函数返回之后调用，result 是返回值或错误
+	ExitFunction(fn *object.Function, result object.Object)
+}
+
+// 不做任何事的钩子，嵌入到只关心部分事件的钩子中
+type NopHooks struct{}
+
+func (NopHooks) LoadProgram(*ast.Program, string)                                         {}
+func (NopHooks) BeforeEval(ast.Node, *object.Environment) error                           { return nil }
+func (NopHooks) EnterFunction(*object.Function, *ast.CallExpression, *object.Environment) {}
+func (NopHooks) ExitFunction(*object.Function, object.Object)                             {}
+
+// 设置钩子，nil 表示不使用钩子
+func (e *Evaluator) SetHooks(hooks Hooks) {
+	e.hooks = hooks
+}
+
+// 当前的钩子
+func (e *Evaluator) Hooks() Hooks {
+	return e.hooks
+}
+
+// 通知钩子将要求值 path 中的代码，没有设置钩子时什么也不做。
+// 模块由求值器自己加载，宿主程序在求值代码文件之前调用
+func (e *Evaluator) LoadProgram(program *ast.Program, path string) {
+	if e.hooks != nil {
+		e.hooks.LoadProgram(program, path)
+	}
+}
@@ -0,0 +1,194 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"monkey/object"
+)
+
+// 默认的最大函数调用深度，防止无限递归耗尽 go 的栈
+const DefaultMaxDepth = 10000
+
+// 求值最多递归的层数。解析器限制了表达式的嵌套，但每层函数调用中的表达式都会叠加，
+// 超出后中止执行，而不是耗尽 go 的栈
+const maxEvalDepth = 200000
+
+// 每执行多少步检查一次 ctx 是否已取消
+const cancelCheckInterval = 256
+
+// 执行限制，字段为 0 表示不限制
+type Limits struct {
+	MaxSteps  int64 // 最多求值的 AST 节点数
+	MaxMemory int64 // 近似的内存分配上限（字节），统计字符串、数组、hash、函数和调用环境等
+	MaxDepth  int   // 最大函数调用深度
+}
+
+// 一次执行（EvalContext 或 ApplyFunctionContext）的状态
+type runState struct {
+	ctx    context.Context
+	nested int // 嵌套的执行层数，如内置函数在执行中回调了 ApplyFunction
+	steps  int64
+	memory int64
+	depth  int
+	level  int           // 当前求值的递归层数
+	halt   *object.Error // 中止执行的原因，设置后所有求值都直接返回它
+}
+
+// 设置执行限制
+func (e *Evaluator) SetLimits(limits Limits) {
+	e.limits = limits
+}
+
+// 当前的执行限制
+func (e *Evaluator) Limits() Limits {
+	return e.limits
+}
+
+// 开始一次执行，返回结束时调用的函数。
+// 嵌套的执行沿用最外层的 ctx 和预算
+func (e *Evaluator) begin(ctx context.Context) func() {
+	if e.run.nested == 0 {
+		e.run = runState{ctx: ctx}
+	}
+	e.run.nested++
+	return func() { e.run.nested-- }
+}
+
+// 中止当前的执行
+func (e *Evaluator) abort(format string, a ...interface{}) *object.Error {
+	e.run.halt = newError(format, a...)
+	return e.run.halt
+}
+
+// 计一步，超出步数预算或 ctx 取消时返回错误
+func (e *Evaluator) step() *object.Error {
+	r := &e.run
+	if r.halt != nil {
+		return r.halt
+	}
+	r.steps++
+	if e.limits.MaxSteps > 0 && r.steps > e.limits.MaxSteps {
+		return e.abort("step budget exceeded (max %d)", e.limits.MaxSteps)
+	}
+	if r.ctx != nil && (r.steps-1)%cancelCheckInterval == 0 {
+		if err := r.ctx.Err(); err != nil {
+			return e.abort("execution cancelled: %s", err)
+		}
+	}
+	return nil
+}
+
+// 记录新分配的对象，超出内存预算时返回错误
+func (e *Evaluator) alloc(obj object.Object) *object.Error {
+	return e.allocBytes(sizeOf(obj))
+}
+
+func (e *Evaluator) allocBytes(size int64) *object.Error {
+	if e.run.halt != nil {
+		return e.run.halt
+	}
+	e.run.memory += size
+	if e.limits.MaxMemory > 0 && e.run.memory > e.limits.MaxMemory {
+		return e.abort("memory budget exceeded (max %d bytes)", e.limits.MaxMemory)
+	}
+	return nil
+}
+
+// 检查再分配 size 字节是否会超出内存预算，但不计入已分配的内存。
+// 内置函数在分配大的对象之前调用，避免先分配再失败
+func (e *Evaluator) checkAlloc(size int64) *object.Error {
+	if e.run.halt != nil {
+		return e.run.halt
+	}
+	if e.limits.MaxMemory > 0 && e.run.memory+size > e.limits.MaxMemory {
+		return e.abort("memory budget exceeded (max %d bytes)", e.limits.MaxMemory)
+	}
+	return nil
+}
+
+// 内置函数一次生成的结果（字符串、数组等）的最大字节数，没有设置内存预算时也不能超过
+const maxResultSize = math.MaxInt32
+
+// 还可以分配给一个结果的字节数
+func (e *Evaluator) available() int64 {
+	if e.limits.MaxMemory > 0 && e.limits.MaxMemory-e.run.memory < maxResultSize {
+		return e.limits.MaxMemory - e.run.memory
+	}
+	return maxResultSize
+}
+
+// 内置函数在分配结果之前检查结果的大小，过大或超出内存预算时返回错误
+func (e *Evaluator) checkResult(name string, size int64) *object.Error {
+	if err := e.checkAlloc(size); err != nil {
+		return err
+	}
+	if size > maxResultSize {
+		return newError("`%s` result too large: %d bytes", name, size)
+	}
+	return nil
+}
+
+// 有大小上限的缓冲区，用来接收长度事先未知的结果，如文件内容和进程的输出。
+// 写入超出上限时返回错误并记录 exceeded
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(b.buf.Len())+int64(len(p)) > b.limit {
+		b.exceeded = true
+		return 0, errors.New("size limit exceeded")
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string { return b.buf.String() }
+
+// 进入一层递归的求值
+func (e *Evaluator) enterEval() *object.Error {
+	if e.run.level >= maxEvalDepth {
+		return e.abort("maximum nesting depth exceeded (max %d)", maxEvalDepth)
+	}
+	e.run.level++
+	return nil
+}
+
+// 进入一层函数调用
+func (e *Evaluator) enterCall() *object.Error {
+	if e.limits.MaxDepth > 0 && e.run.depth >= e.limits.MaxDepth {
+		return e.abort("maximum call depth exceeded (max %d)", e.limits.MaxDepth)
+	}
+	e.run.depth++
+	return nil
+}
+
+func (e *Evaluator) exitCall() {
+	e.run.depth--
+}
+
+// 对象占用内存的粗略估计，只计算对象本身，不包括引用的元素
+func sizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case nil:
+		return 0
+	case *object.String:
+		return 16 + int64(len(obj.Value))
+	case *object.Array:
+		return 24 + 16*int64(len(obj.Elements))
+	case *object.Hash:
+		return 48 + 64*int64(len(obj.Pairs))
+	case *object.Function:
+		return 64
+	default:
+		return 16
+	}
+}
+
+// 调用函数时新建的环境的大小
+func envSize(args int) int64 {
+	return 64 + 32*int64(args)
+}
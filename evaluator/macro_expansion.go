@@ -0,0 +1,120 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+)
+
+// 收集程序顶层的宏定义（let name = macro(...) { ... };）放入 env，
+// 并把这些定义从程序中删除
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	definitions := []int{}
+	for i, statement := range program.Statements {
+		if isMacroDefinition(statement) {
+			addMacro(statement, env)
+			definitions = append(definitions, i)
+		}
+	}
+	for i := len(definitions) - 1; i >= 0; i-- {
+		definitionIndex := definitions[i]
+		program.Statements = append(
+			program.Statements[:definitionIndex],
+			program.Statements[definitionIndex+1:]...,
+		)
+	}
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	letStatement, ok := node.(*ast.LetStatement)
+	if !ok {
+		return false
+	}
+	_, ok = letStatement.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(stmt ast.Statement, env *object.Environment) {
+	letStatement := stmt.(*ast.LetStatement)
+	macroLiteral := letStatement.Value.(*ast.MacroLiteral)
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Env:        env,
+		Body:       macroLiteral.Body,
+	}
+	env.Set(letStatement.Name.Value, macro)
+}
+
+// 展开程序中所有的宏调用：宏的参数是未求值的 Quote，
+// 宏体的求值结果（必须是 Quote）替换掉调用处的节点
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	return New().ExpandMacros(program, env)
+}
+
+// 使用 e 对宏体求值，宏体可以使用 e 的内置函数，并受 e 的执行限制
+func (e *Evaluator) ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	var err error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		callExpression, ok := node.(*ast.CallExpression)
+		if !ok || err != nil {
+			return node
+		}
+		macro, ok := isMacroCall(callExpression, env)
+		if !ok {
+			return node
+		}
+		if len(callExpression.Arguments) != len(macro.Parameters) {
+			err = fmt.Errorf("wrong number of arguments to macro `%s`. got=%d, want=%d",
+				callExpression.Function, len(callExpression.Arguments), len(macro.Parameters))
+			return node
+		}
+		args := quoteArgs(callExpression)
+		evalEnv := extendMacroEnv(macro, args)
+		evaluated := unwrapReturnValue(e.Eval(macro.Body, evalEnv))
+		if evaluated == nil {
+			evaluated = NULL
+		}
+		if errObj, ok := evaluated.(*object.Error); ok {
+			err = errors.New(errObj.Message)
+			return node
+		}
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			err = fmt.Errorf("macro `%s` must return QUOTE, got %s",
+				callExpression.Function, evaluated.Type())
+			return node
+		}
+		return quote.Node
+	})
+	return expanded, err
+}
+
+func isMacroCall(exp *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	identifier, ok := exp.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	obj, ok := env.Get(identifier.Value)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func quoteArgs(exp *ast.CallExpression) []*object.Quote {
+	args := []*object.Quote{}
+	for _, a := range exp.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+	return args
+}
+
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+	for paramIdx, param := range macro.Parameters {
+		extended.Set(param.Value, args[paramIdx])
+	}
+	return extended
+}
@@ -0,0 +1,61 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+	"path/filepath"
+)
+
+// 各类型支持的方法，方法名对应同名的内置函数，调用时接收者作为第一个参数传入，
+// 如 "abc".upper() 等价于 upper("abc")，arr.map(f) 等价于 map(arr, f)
+var methods = map[object.ObjectType][]string{
+	object.STRING_OBJ: {
+		"len", "split", "trim", "trim_left", "trim_right", "upper", "lower",
+		"contains", "starts_with", "ends_with", "index_of", "replace", "repeat",
+		"substr", "format", "chars", "bytes", "ord", "int",
+	},
+	object.ARRAY_OBJ: {
+		"len", "first", "last", "rest", "push", "join",
+		"map", "filter", "reduce", "find", "any", "all", "sort", "sort_by",
+	},
+}
+
+// 成员访问 obj.name：
+// 模块返回导出的绑定，hash 等价于 obj["name"]，其他类型返回绑定了接收者的方法
+func (e *Evaluator) evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := e.eval(node.Object, env)
+	if isError(obj) {
+		return obj
+	}
+	name := node.Property.Value
+	switch obj := obj.(type) {
+	case *object.Module:
+		val, ok := obj.Exports[name]
+		if !ok {
+			return newError("module %q has no exported member %s",
+				filepath.Base(obj.Path), name)
+		}
+		return val
+	case *object.Hash:
+		return evalHashIndexExpression(obj, &object.String{Value: name})
+	default:
+		return e.boundMethod(obj, name)
+	}
+}
+
+// 查找接收者类型的方法，返回把接收者绑定为第一个参数的内置函数
+func (e *Evaluator) boundMethod(receiver object.Object, name string) object.Object {
+	for _, method := range methods[receiver.Type()] {
+		if method != name {
+			continue
+		}
+		builtin, ok := e.builtins[name]
+		if !ok {
+			break
+		}
+		return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			return builtin.Fn(append([]object.Object{receiver}, args...)...)
+		}}
+	}
+	return newError("%s has no method %s", receiver.Type(), name)
+}
@@ -0,0 +1,131 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// import 声明：加载模块，并把模块或选中的导出绑定到当前环境。
+// 脚本目录下相对路径的模块总是可以加载，其他路径需要 import 能力
+func (e *Evaluator) evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	if len(e.importStack) == 0 {
+		e.importRoot = env.Dir()
+	}
+	path := node.Path.Value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(env.Dir(), path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return newError("cannot import %q: %s", node.Path.Value, err)
+	}
+	if e.caps&CapImport == 0 && (filepath.IsAbs(node.Path.Value) || !withinDir(e.importRoot, path)) {
+		return newError("capability denied: importing %q from outside the script directory requires %s",
+			node.Path.Value, CapImport)
+	}
+	loaded := e.loadModule(path)
+	if isError(loaded) {
+		return loaded
+	}
+	module := loaded.(*object.Module)
+	if len(node.Names) == 0 {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if node.Alias != nil {
+			name = node.Alias.Value
+		}
+		env.Set(name, module)
+		return nil
+	}
+	for _, name := range node.Names {
+		val, ok := module.Exports[name.Value]
+		if !ok {
+			return newError("module %q has no exported member %s",
+				filepath.Base(path), name.Value)
+		}
+		env.Set(name.Value, val)
+	}
+	return nil
+}
+
+// path 是否位于目录 dir 之内，符号链接解析到真实的位置再比较。dir 为空时返回 false
+func withinDir(dir, path string) bool {
+	if dir == "" {
+		return false
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// 加载模块文件：在独立的环境里求值一次并缓存，只暴露 export 的绑定
+func (e *Evaluator) loadModule(path string) object.Object {
+	if module, ok := e.modules[path]; ok {
+		return module
+	}
+	for i, loading := range e.importStack {
+		if loading == path {
+			cycle := append(append([]string{}, e.importStack[i:]...), path)
+			for j := range cycle {
+				cycle[j] = filepath.Base(cycle[j])
+			}
+			return newError("import cycle detected: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return newError("cannot import %q: %s", path, err)
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("parse errors in %s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+
+	// 宏只在模块内部可见
+	macros := object.NewEnvironment()
+	DefineMacros(program, macros)
+	expanded, err := e.ExpandMacros(program, macros)
+	if err != nil {
+		return newError("macro expansion failed in %s: %s", path, err)
+	}
+	program = expanded.(*ast.Program)
+	e.LoadProgram(program, path)
+
+	e.importStack = append(e.importStack, path)
+	defer func() { e.importStack = e.importStack[:len(e.importStack)-1] }()
+
+	env := object.NewEnvironment()
+	env.SetDir(filepath.Dir(path))
+	if result := e.eval(program, env); isError(result) {
+		return result
+	}
+	module := &object.Module{Path: path, Exports: map[string]object.Object{}}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			name := export.Statement.Name.Value
+			val, ok := env.Get(name)
+			if !ok {
+				// 顶层的 return 提前结束了模块，后面导出的绑定没有定义
+				return newError("module %q returned before exporting %s",
+					filepath.Base(path), name)
+			}
+			module.Exports[name] = val
+		}
+	}
+	e.modules[path] = module
+	return module
+}
@@ -0,0 +1,87 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/object"
+	"monkey/token"
+)
+
+// quote(exp)：不对 exp 求值，而是返回包装了 exp 的 Quote。
+// exp 中的 unquote(x) 会被替换为 x 求值结果对应的 ast 节点
+func (e *Evaluator) quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError("wrong number of arguments to `quote`. got=%d, want=1",
+			len(call.Arguments))
+	}
+	node, err := e.evalUnquoteCalls(call.Arguments[0], env)
+	if err != nil {
+		return err
+	}
+	return &object.Quote{Node: node}
+}
+
+// 对 quoted 中的 unquote 调用求值
+func (e *Evaluator) evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var err *object.Error
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || err != nil || !isUnquoteCall(call) {
+			return node
+		}
+		if len(call.Arguments) != 1 {
+			err = newError("wrong number of arguments to `unquote`. got=%d, want=1",
+				len(call.Arguments))
+			return node
+		}
+		unquoted := e.eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted.(*object.Error)
+			return node
+		}
+		converted, convErr := convertObjectToASTNode(unquoted)
+		if convErr != nil {
+			err = convErr
+			return node
+		}
+		return converted
+	})
+	return node, err
+}
+
+func isQuoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "quote"
+}
+
+func isUnquoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
+
+// 把 unquote 的结果转换回 ast 节点
+func convertObjectToASTNode(obj object.Object) (ast.Node, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", obj.Value)}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Float:
+		t := token.Token{Type: token.FLOAT, Literal: obj.Inspect()}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, nil
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Quote:
+		return obj.Node, nil
+	default:
+		return nil, newError("cannot unquote %s", obj.Type())
+	}
+}
@@ -0,0 +1,456 @@
+// formatter 包把 monkey 源码格式化为统一的风格：
+// 四个空格缩进，每条语句单独一行，运算符两侧各一个空格，只保留必要的括号。
+// 注释和语句之间的空行（连续多个空行合并为一个）会被保留。
+// 注释跟随它后面的语句、列表元素或 else 输出；语句中间其他位置的注释无法跟随某个节点时，
+// 这条语句保留源码中原来的写法
+package formatter
+
+import (
+	"bytes"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+)
+
+const indentUnit = "    "
+
+// 源码有语法错误，无法格式化
+type ParseError struct {
+	Messages []string
+}
+
+func (e *ParseError) Error() string {
+	return "parser errors: " + strings.Join(e.Messages, "; ")
+}
+
+// 格式化一段源码
+func Format(src string) (string, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", &ParseError{Messages: p.Errors()}
+	}
+	return Program(program, l.Comments(), src), nil
+}
+
+// 格式化已经解析好的程序。
+// comments 是 lexer 读到的注释，src 是原始的源码，用于判断注释的位置和语句之间的空行
+func Program(program *ast.Program, comments []token.Token, src string) string {
+	f := &formatter{comments: comments, src: src, lines: strings.Split(src, "\n")}
+	f.blockStart = true
+	f.statements(program.Statements, ast.Position{Line: len(f.lines) + 1, Column: 1})
+	f.flushComments(ast.Position{Line: 1 << 30})
+	return f.out.String()
+}
+
+type formatter struct {
+	out        bytes.Buffer
+	indent     int
+	comments   []token.Token // 还没有输出的注释
+	src        string
+	lines      []string
+	blockStart bool // 当前在代码块的开头，还没有输出任何一行
+	stray      bool // 当前语句中有注释无法跟随它后面的节点输出
+}
+
+// 开始新的一行。line 是这一行内容在源码中的行号，源码中它的上一行是空行时先输出一个空行
+func (f *formatter) newline(line int) {
+	if !f.blockStart && f.isBlankLine(line-1) {
+		f.out.WriteString("\n")
+	}
+	f.blockStart = false
+	f.out.WriteString(strings.Repeat(indentUnit, f.indent))
+}
+
+func (f *formatter) isBlankLine(line int) bool {
+	return line >= 1 && line <= len(f.lines) && strings.TrimSpace(f.lines[line-1]) == ""
+}
+
+// 输出 pos 之前的所有注释。
+// 和代码在同一行的注释跟在上一行的末尾，单独一行的注释按当前的缩进输出
+func (f *formatter) flushComments(pos ast.Position) {
+	for len(f.comments) > 0 && ast.TokenPosition(f.comments[0]).Before(pos) {
+		comment := f.comments[0]
+		f.comments = f.comments[1:]
+		if f.isTrailing(comment) && f.out.Len() > 0 {
+			out := bytes.TrimRight(f.out.Bytes(), "\n")
+			f.out.Truncate(len(out))
+			f.out.WriteString(" " + comment.Literal + "\n")
+			continue
+		}
+		f.newline(comment.Line)
+		f.out.WriteString(comment.Literal + "\n")
+	}
+}
+
+// 注释前面是否有代码
+func (f *formatter) isTrailing(comment token.Token) bool {
+	if comment.Line < 1 || comment.Line > len(f.lines) {
+		return false
+	}
+	runes := []rune(f.lines[comment.Line-1])
+	if comment.Column-1 > len(runes) {
+		return false
+	}
+	return strings.TrimSpace(string(runes[:comment.Column-1])) != ""
+}
+
+// pos 之前是否还有没有输出的注释
+func (f *formatter) hasCommentBefore(pos ast.Position) bool {
+	return len(f.comments) > 0 && ast.TokenPosition(f.comments[0]).Before(pos)
+}
+
+// 输出一组语句，end 是最后一条语句之后的位置（代码块的 } 或源码的末尾）
+func (f *formatter) statements(stmts []ast.Statement, end ast.Position) {
+	for i, stmt := range stmts {
+		next := end
+		if i < len(stmts)-1 {
+			next = ast.PositionOf(stmts[i+1])
+		}
+		f.statement(stmt, next)
+	}
+}
+
+// 输出一条语句，单独占一行。next 是下一条语句的位置
+func (f *formatter) statement(stmt ast.Statement, next ast.Position) {
+	pos := ast.PositionOf(stmt)
+	f.flushComments(pos)
+	f.newline(pos.Line)
+	mark, comments, stray := f.out.Len(), f.comments, f.stray
+	f.stray = false
+	f.statementBody(stmt)
+	if f.stray || f.hasCommentBefore(ast.Position{Line: lastLine(stmt)}) {
+		// 语句中间还有没输出的注释，如 a +\n// 注释\nb，保留语句原来的写法
+		f.out.Truncate(mark)
+		f.comments = comments
+		f.out.WriteString(f.source(pos, next))
+		for f.hasCommentBefore(next) {
+			f.comments = f.comments[1:]
+		}
+	}
+	f.stray = stray
+	f.out.WriteString("\n")
+}
+
+// 语句最后一行的行号：语句中的节点和各种右括号所在行的最大值
+func lastLine(stmt ast.Statement) int {
+	last := 0
+	ast.Inspect(stmt, func(node ast.Node) bool {
+		if node == nil {
+			return false
+		}
+		line, _ := ast.Pos(node)
+		var end token.Token
+		switch node := node.(type) {
+		case *ast.BlockStatement:
+			end = node.End
+		case *ast.CallExpression:
+			end = node.End
+		case *ast.ArrayLiteral:
+			end = node.End
+		case *ast.HashLiteral:
+			end = node.End
+		}
+		if end.Line > line {
+			line = end.Line
+		}
+		if line > last {
+			last = line
+		}
+		return true
+	})
+	return last
+}
+
+// 源码中从 start 到 end 之间的代码，去掉末尾的空白
+func (f *formatter) source(start, end ast.Position) string {
+	return strings.TrimRight(f.src[f.offset(start):f.offset(end)], " \t\r\n")
+}
+
+// 位置在源码中的字节偏移，列号按字符计
+func (f *formatter) offset(pos ast.Position) int {
+	if pos.Line > len(f.lines) {
+		return len(f.src)
+	}
+	offset := 0
+	for _, line := range f.lines[:pos.Line-1] {
+		offset += len(line) + 1
+	}
+	line := f.lines[pos.Line-1]
+	for i := range line {
+		if pos.Column <= 1 {
+			return offset + i
+		}
+		pos.Column--
+	}
+	return offset + len(line)
+}
+
+func (f *formatter) statementBody(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		f.letStatement(stmt)
+	case *ast.ExportStatement:
+		f.out.WriteString("export ")
+		f.letStatement(stmt.Statement)
+	case *ast.ReturnStatement:
+		f.out.WriteString("return")
+		if stmt.ReturnValue != nil {
+			f.out.WriteString(" ")
+			f.expression(stmt.ReturnValue, parser.LOWEST)
+		}
+		f.out.WriteString(";")
+	case *ast.ImportStatement:
+		f.out.WriteString("import ")
+		if len(stmt.Names) > 0 {
+			f.out.WriteString("{ ")
+			for i, name := range stmt.Names {
+				if i > 0 {
+					f.out.WriteString(", ")
+				}
+				f.out.WriteString(name.Value)
+			}
+			f.out.WriteString(" } from ")
+			f.stringLiteral(stmt.Path.Value)
+		} else {
+			f.stringLiteral(stmt.Path.Value)
+			if stmt.Alias != nil {
+				f.out.WriteString(" as " + stmt.Alias.Value)
+			}
+		}
+		f.out.WriteString(";")
+	case *ast.ExpressionStatement:
+		f.expression(stmt.Expression, parser.LOWEST)
+		// if 表达式作为语句时不需要分号
+		if _, ok := stmt.Expression.(*ast.IfExpression); !ok {
+			f.out.WriteString(";")
+		}
+	}
+}
+
+func (f *formatter) letStatement(stmt *ast.LetStatement) {
+	f.out.WriteString("let " + stmt.Name.Value + " = ")
+	f.expression(stmt.Value, parser.LOWEST)
+	f.out.WriteString(";")
+}
+
+// 字符串不支持转义，原样输出
+func (f *formatter) stringLiteral(value string) {
+	f.out.WriteString(`"` + value + `"`)
+}
+
+// 表达式的优先级，用于判断是否需要加括号
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression:
+		return parser.CALL
+	default:
+		return parser.INDEX + 1
+	}
+}
+
+// 输出表达式，优先级低于 minPrecedence 时加上括号
+func (f *formatter) expression(exp ast.Expression, minPrecedence int) {
+	if precedence(exp) < minPrecedence {
+		f.out.WriteString("(")
+		f.expression(exp, parser.LOWEST)
+		f.out.WriteString(")")
+		return
+	}
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		f.out.WriteString(exp.Value)
+	case *ast.IntegerLiteral:
+		f.out.WriteString(exp.Token.Literal)
+	case *ast.FloatLiteral:
+		f.out.WriteString(exp.Token.Literal)
+	case *ast.Boolean:
+		f.out.WriteString(exp.Token.Literal)
+	case *ast.StringLiteral:
+		f.stringLiteral(exp.Value)
+	case *ast.PrefixExpression:
+		f.out.WriteString(exp.Operator)
+		f.expression(exp.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		p := precedence(exp)
+		// 运算符都是左结合的，右侧优先级相同时也要加括号
+		f.expression(exp.Left, p)
+		f.out.WriteString(" " + exp.Operator + " ")
+		f.expression(exp.Right, p+1)
+	case *ast.IfExpression:
+		f.out.WriteString("if (")
+		f.expression(exp.Condition, parser.LOWEST)
+		f.out.WriteString(") ")
+		// 两个分支要么都是单行，要么都是多行
+		inline := f.isInline(exp.Consequence) &&
+			(exp.Alternative == nil || f.isInline(exp.Alternative))
+		f.block(exp.Consequence, inline)
+		if exp.Alternative != nil {
+			if alt := ast.TokenPosition(exp.Alternative.Token); f.hasCommentBefore(alt) {
+				// } 和 else 之间的注释保留在原来的位置，else 另起一行
+				f.out.WriteString("\n")
+				f.flushComments(alt)
+				f.out.WriteString(strings.Repeat(indentUnit, f.indent) + "else ")
+			} else {
+				f.out.WriteString(" else ")
+			}
+			f.block(exp.Alternative, inline)
+		}
+	case *ast.FunctionLiteral:
+		f.out.WriteString("fn")
+		f.parameters(exp.Parameters)
+		f.block(exp.Body, f.isInline(exp.Body))
+	case *ast.MacroLiteral:
+		f.out.WriteString("macro")
+		f.parameters(exp.Parameters)
+		f.block(exp.Body, f.isInline(exp.Body))
+	case *ast.CallExpression:
+		f.expression(exp.Function, parser.CALL)
+		f.list("(", ")", exp.Token, exp.End, exp.Arguments)
+	case *ast.ArrayLiteral:
+		f.list("[", "]", exp.Token, exp.End, exp.Elements)
+	case *ast.IndexExpression:
+		f.expression(exp.Left, parser.CALL)
+		f.out.WriteString("[")
+		f.expression(exp.Index, parser.LOWEST)
+		f.out.WriteString("]")
+	case *ast.SliceExpression:
+		f.expression(exp.Left, parser.CALL)
+		f.out.WriteString("[")
+		f.optionalExpression(exp.Start)
+		f.out.WriteString(":")
+		f.optionalExpression(exp.End)
+		if exp.Step != nil {
+			f.out.WriteString(":")
+			f.expression(exp.Step, parser.LOWEST)
+		}
+		f.out.WriteString("]")
+	case *ast.MemberExpression:
+		f.expression(exp.Object, parser.CALL)
+		f.out.WriteString("." + exp.Property.Value)
+	case *ast.HashLiteral:
+		f.hash(exp)
+	}
+}
+
+func (f *formatter) optionalExpression(exp ast.Expression) {
+	if exp != nil {
+		f.expression(exp, parser.LOWEST)
+	}
+}
+
+func (f *formatter) parameters(params []*ast.Identifier) {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.Value
+	}
+	f.out.WriteString("(" + strings.Join(names, ", ") + ") ")
+}
+
+// 输出逗号分隔的列表。第一个元素和左括号不在同一行或者列表中有注释时，每个元素单独一行
+func (f *formatter) list(open, close string, start, end token.Token, items []ast.Expression) {
+	f.out.WriteString(open)
+	if len(items) == 0 || ast.PositionOf(items[0]).Line == start.Line && !f.hasCommentBefore(ast.TokenPosition(end)) {
+		for i, item := range items {
+			if i > 0 {
+				f.out.WriteString(", ")
+			}
+			f.expression(item, parser.LOWEST)
+		}
+		f.out.WriteString(close)
+		return
+	}
+	f.multiline(len(items), ast.TokenPosition(end), func(i int) ast.Node { return items[i] }, func(i int) {
+		f.expression(items[i], parser.LOWEST)
+	})
+	f.out.WriteString(close)
+}
+
+func (f *formatter) hash(hash *ast.HashLiteral) {
+	keys := hash.OrderedKeys()
+	entry := func(i int) {
+		f.expression(keys[i], parser.LOWEST)
+		f.out.WriteString(": ")
+		f.expression(hash.Pairs[keys[i]], parser.LOWEST)
+	}
+	f.out.WriteString("{")
+	if len(keys) == 0 || ast.PositionOf(keys[0]).Line == hash.Token.Line && !f.hasCommentBefore(ast.TokenPosition(hash.End)) {
+		for i := range keys {
+			if i > 0 {
+				f.out.WriteString(", ")
+			}
+			entry(i)
+		}
+		f.out.WriteString("}")
+		return
+	}
+	f.multiline(len(keys), ast.TokenPosition(hash.End), func(i int) ast.Node { return keys[i] }, entry)
+	f.out.WriteString("}")
+}
+
+// 多行输出 n 个元素，元素之间用逗号分隔，每个元素前先输出它前面的注释，
+// 最后一个元素之后、右括号 end 之前的注释输出在右括号之前
+func (f *formatter) multiline(n int, end ast.Position, node func(int) ast.Node, item func(int)) {
+	f.out.WriteString("\n")
+	f.indent++
+	f.blockStart = true
+	for i := 0; i < n; i++ {
+		pos := ast.PositionOf(node(i))
+		f.flushComments(pos)
+		f.newline(pos.Line)
+		item(i)
+		if i < n-1 {
+			f.out.WriteString(",")
+		}
+		f.out.WriteString("\n")
+	}
+	f.flushComments(end)
+	f.indent--
+	f.out.WriteString(strings.Repeat(indentUnit, f.indent))
+}
+
+// 代码块是否可以单行输出：没有注释，为空或者只有一个表达式、且源码中写在同一行，如 fn(x) { x * 2 }
+func (f *formatter) isInline(block *ast.BlockStatement) bool {
+	if f.hasCommentBefore(ast.TokenPosition(block.End)) {
+		return false
+	}
+	if len(block.Statements) == 0 {
+		return true
+	}
+	stmt, ok := block.Statements[0].(*ast.ExpressionStatement)
+	return ok && len(block.Statements) == 1 && ast.PositionOf(stmt).Line == block.Token.Line
+}
+
+// 输出代码块
+func (f *formatter) block(block *ast.BlockStatement, inline bool) {
+	end := ast.TokenPosition(block.End)
+	if inline {
+		if len(block.Statements) == 0 {
+			f.out.WriteString("{}")
+			return
+		}
+		stmt := block.Statements[0].(*ast.ExpressionStatement)
+		f.out.WriteString("{ ")
+		f.expression(stmt.Expression, parser.LOWEST)
+		f.out.WriteString(" }")
+		return
+	}
+	// { 之前的注释不属于代码块，如 fn(a, // 注释\n b) {
+	if f.hasCommentBefore(ast.TokenPosition(block.Token)) {
+		f.stray = true
+	}
+	f.out.WriteString("{\n")
+	f.indent++
+	f.blockStart = true
+	f.statements(block.Statements, end)
+	f.flushComments(end)
+	f.indent--
+	f.out.WriteString(strings.Repeat(indentUnit, f.indent) + "}")
+}
@@ -0,0 +1,189 @@
+package formatter
+
+import (
+	"flag"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the formatter output")
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"let add=fn(a,b){a+b};add(1,2)", "let add = fn(a, b) { a + b };\nadd(1, 2);\n"},
+		{"(1 + 2) * 3 - (4 - 5) - -x", "(1 + 2) * 3 - (4 - 5) - -x;\n"},
+		{"((a * b)) + (c * d)", "a * b + c * d;\n"},
+		{"a - (b + c); (a - b) + c", "a - (b + c);\na - b + c;\n"},
+		{"(-a)[0]; -a[0]; !(a == b); (f)(x); f(x)[0]", "(-a)[0];\n-a[0];\n!(a == b);\nf(x);\nf(x)[0];\n"},
+		{"arr[1:][::2]; arr[:-1]; m.v; (a + b).c", "arr[1:][::2];\narr[:-1];\nm.v;\n(a + b).c;\n"},
+		{`import {a,b} from "x.mk"; import "y.mk" as y; export let z = 1.50`,
+			"import { a, b } from \"x.mk\";\nimport \"y.mk\" as y;\nexport let z = 1.50;\n"},
+		{`{"a":1,"b":[true,false]}`, "{\"a\": 1, \"b\": [true, false]};\n"},
+		{"let f = fn() {}; let g = macro(x) { quote(unquote(x)) }",
+			"let f = fn() {};\nlet g = macro(x) { quote(unquote(x)) };\n"},
+		{"let f = fn(n) { if (n < 2) { return n; } fib(n - 1) }",
+			"let f = fn(n) {\n    if (n < 2) {\n        return n;\n    }\n    fib(n - 1);\n};\n"},
+		{"if (x) { a } else { b; c }",
+			"if (x) {\n    a;\n} else {\n    b;\n    c;\n}\n"},
+		{"if (x) { a } else { b }", "if (x) { a } else { b }\n"},
+		{"let f = fn(x) {\nx }", "let f = fn(x) {\n    x;\n};\n"},
+		{"let cfg = {\n\"a\": 1, \"b\": 2}; let xs = [\n1,\n2]",
+			"let cfg = {\n    \"a\": 1,\n    \"b\": 2\n};\nlet xs = [\n    1,\n    2\n];\n"},
+		{"f(\n1, [2,\n3])", "f(\n    1,\n    [2, 3]\n);\n"},
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{"let f = fn() {\n\n  a;\n\n  b;\n\n};", "let f = fn() {\n    a;\n\n    b;\n};\n"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := Format(tt.input)
+		if err != nil {
+			t.Fatalf("Format(%q) returned error: %s", tt.input, err)
+		}
+		if got != tt.expected {
+			t.Errorf("Format(%q) wrong.\ngot:\n%s\nwant:\n%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	input := `// header
+let a = 1; // trailing
+// before b
+
+// after blank
+let b = fn() { // open
+  // inside
+  a;
+  // end of block
+};
+let c = {
+  // first
+  "x": 1, // one
+  "y": 2
+};
+let d = fn() {
+  // only a comment
+};
+let e = fn() { a } // after inline
+// footer`
+	expected := `// header
+let a = 1; // trailing
+// before b
+
+// after blank
+let b = fn() { // open
+    // inside
+    a;
+    // end of block
+};
+let c = {
+    // first
+    "x": 1, // one
+    "y": 2
+};
+let d = fn() {
+    // only a comment
+};
+let e = fn() { a }; // after inline
+// footer
+`
+	got, err := Format(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != expected {
+		t.Errorf("wrong result.\ngot:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+// 格式化不能改变程序的含义，并且格式化的结果再次格式化时保持不变
+func TestFormatPreservesMeaning(t *testing.T) {
+	inputs := []string{
+		"let r = (1 + 2) * 3 - (4 - 5) - -x * !y;",
+		"a / (b / c) / d; a < (b > c); (a == b) != c;",
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; puts(fib(10));",
+		"fn(x) { x }(1); if (a) { b }[0]; [1, 2][0:1]; {\"k\": fn() { 1 }}[\"k\"]();",
+		"import \"lib.mk\" as lib; lib.f(lib.v)[1:2:3].x;",
+		"let m = macro(a, b) { quote(unquote(b) - unquote(a)) }; m(1 + 2, 3 * 4);",
+	}
+	for _, input := range inputs {
+		first, err := Format(input)
+		if err != nil {
+			t.Fatalf("Format(%q) returned error: %s", input, err)
+		}
+		if parse(t, first) != parse(t, input) {
+			t.Errorf("meaning changed.\ninput:  %s\nformatted: %s", parse(t, input), parse(t, first))
+		}
+		second, err := Format(first)
+		if err != nil {
+			t.Fatalf("Format(%q) returned error: %s", first, err)
+		}
+		if second != first {
+			t.Errorf("not idempotent.\nfirst:\n%s\nsecond:\n%s", first, second)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program.String()
+}
+
+func TestFormatParseError(t *testing.T) {
+	_, err := Format("let = 5;")
+	if _, ok := err.(*ParseError); !ok {
+		t.Fatalf("expected *ParseError. got=%T (%v)", err, err)
+	}
+}
+
+// testdata 下每个 .mk 文件格式化的结果与同名的 .golden 文件相同，且再次格式化时保持不变
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".mk")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Format(string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(file, ".mk") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(expected) {
+				t.Errorf("wrong result.\ngot:\n%s\nwant:\n%s", got, expected)
+			}
+			if again, _ := Format(got); again != got {
+				t.Errorf("not idempotent.\nfirst:\n%s\nsecond:\n%s", got, again)
+			}
+			if parse(t, got) != parse(t, string(src)) {
+				t.Errorf("meaning changed")
+			}
+		})
+	}
+}
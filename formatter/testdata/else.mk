@@ -0,0 +1,12 @@
+if (x) {
+  a;
+} // after then
+// before else
+else {
+  b;
+}
+let f = fn(n) {
+  if (n) { 1 }
+  // the other case
+  else { 2 }
+};
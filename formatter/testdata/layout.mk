@@ -0,0 +1,13 @@
+let z = a +
+  // middle
+  b;
+let f = fn() {
+    let w = a *
+        // inner
+        b;
+  w
+};
+let g = fn(a,
+  // second parameter
+  b) { a + b }; // trailing
+let last = 1;
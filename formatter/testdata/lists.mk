@@ -0,0 +1,14 @@
+puts(x,
+  // second arg
+  2);
+let y = [1, 2, // two
+  3 // three
+];
+let h = {"a": 1, // one
+  // before b
+  "b": 2};
+f(
+  1,
+  2
+  // after the last argument
+);
@@ -0,0 +1,63 @@
+// fuzzseed 包为模糊测试收集种子输入：已有测试中的字符串字面量和一致性测试集中的脚本
+package fuzzseed
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 读取 dir 中所有 _test.go 文件里的字符串字面量，以及 scripts 目录下所有的 .mk 文件，
+// 去重后按字典序返回
+func Load(dir, scripts string) ([]string, error) {
+	seen := map[string]bool{}
+	files, err := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		ast.Inspect(f, func(node ast.Node) bool {
+			if lit, ok := node.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				if s, err := strconv.Unquote(lit.Value); err == nil {
+					seen[s] = true
+				}
+			}
+			return true
+		})
+	}
+
+	err = filepath.WalkDir(scripts, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".mk") {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			seen[string(data)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seeds := make([]string, 0, len(seen))
+	for s := range seen {
+		seeds = append(seeds, s)
+	}
+	sort.Strings(seeds)
+	return seeds, nil
+}
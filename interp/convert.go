@@ -0,0 +1,117 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+	"monkey/evaluator"
+	"monkey/object"
+	"reflect"
+	"sort"
+)
+
+// 把 go 的值转换为 monkey 的值：
+// nil、bool、整数、浮点数、字符串、切片/数组、key 为字符串的 map，以及已经是 object.Object 的值
+func ToObject(v interface{}) (object.Object, error) {
+	switch v := v.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case object.Object:
+		return v, nil
+	case bool:
+		if v {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case string:
+		return &object.String{Value: v}, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %T to object: %d overflows INTEGER", v, rv.Uint())
+		}
+		return &object.Integer{Value: int64(rv.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: rv.Float()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, rv.Len())
+		for i := range elements {
+			el, err := ToObject(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot convert %T to object: map key must be string", v)
+		}
+		hash := object.NewHash()
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			val, err := ToObject(rv.MapIndex(key).Interface())
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(&object.String{Value: key.String()}, val)
+		}
+		return hash, nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to object", v)
+}
+
+// 把 monkey 的值转换为 go 的值：
+// INTEGER 为 int64，FLOAT 为 float64，STRING 为 string，BOOLEAN 为 bool，NULL 为 nil，
+// ARRAY 为 []interface{}，key 都是字符串的 HASH 为 map[string]interface{}，
+// 其他 HASH 为 map[interface{}]interface{}，key 保留各自的类型，如 {1: "a", "1": "b"} 的两个 key 为 int64(1) 和 "1"。
+// 其他类型（如函数）原样返回
+func ToGo(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		values := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			values[i] = ToGo(el)
+		}
+		return values
+	case *object.Hash:
+		if !stringKeys(obj) {
+			values := make(map[interface{}]interface{}, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				values[ToGo(pair.Key)] = ToGo(pair.Value)
+			}
+			return values
+		}
+		values := make(map[string]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			values[pair.Key.(*object.String).Value] = ToGo(pair.Value)
+		}
+		return values
+	default:
+		return obj
+	}
+}
+
+func stringKeys(hash *object.Hash) bool {
+	for _, pair := range hash.Pairs {
+		if _, ok := pair.Key.(*object.String); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+// interp 包提供在 go 程序中嵌入 monkey 解释器的接口
+package interp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 解析错误
+type ParseError struct {
+	Messages []string
+}
+
+func (e *ParseError) Error() string {
+	return "parser errors: " + strings.Join(e.Messages, "; ")
+}
+
+// 执行时的错误，对应 monkey 里的 object.Error
+type RuntimeError struct {
+	Message string
+}
+
+func (e *RuntimeError) Error() string { return e.Message }
+
+// 宏展开时的错误
+type MacroError struct {
+	Message string
+}
+
+func (e *MacroError) Error() string { return "macro expansion failed: " + e.Message }
+
+// 解释器，每个解释器有自己独立的全局环境和内置函数，多个解释器可以在不同 goroutine 中并发运行
+type Interpreter struct {
+	eval   *evaluator.Evaluator
+	env    *object.Environment
+	macros *object.Environment // 已定义的宏，多次 Run 之间共享
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type Option func(*Interpreter)
+
+// 设置脚本输出（puts、print、printf）写入的位置，默认为 os.Stdout
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) { i.stdout = w }
+}
+
+// 设置脚本错误输出（eprint）写入的位置，默认为 os.Stderr
+func WithStderr(w io.Writer) Option {
+	return func(i *Interpreter) { i.stderr = w }
+}
+
+// 设置解析相对路径 import 时的基准目录，默认为当前工作目录
+func WithDir(dir string) Option {
+	return func(i *Interpreter) { i.env.SetDir(dir) }
+}
+
+// 设置执行限制（步数、内存、调用深度）
+func WithLimits(limits evaluator.Limits) Option {
+	return func(i *Interpreter) { i.eval.SetLimits(limits) }
+}
+
+// 授予脚本的能力，如读写文件、读取环境变量，默认不授予任何能力
+func WithCapabilities(caps evaluator.Capability) Option {
+	return func(i *Interpreter) { i.eval.SetCapabilities(caps) }
+}
+
+func New(opts ...Option) *Interpreter {
+	i := &Interpreter{
+		eval:   evaluator.New(),
+		env:    object.NewEnvironment(),
+		macros: object.NewEnvironment(),
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.eval.SetStdout(i.stdout)
+	i.eval.SetStderr(i.stderr)
+	return i
+}
+
+// 脚本的标准输出
+func (i *Interpreter) Stdout() io.Writer { return i.stdout }
+
+// 底层的求值器，供调试器等工具设置钩子
+func (i *Interpreter) Evaluator() *evaluator.Evaluator { return i.eval }
+
+// 脚本的错误输出，宿主注册的内置函数也可以用它输出诊断信息
+func (i *Interpreter) Stderr() io.Writer { return i.stderr }
+
+// 执行一段代码，返回最后一个表达式的值
+func (i *Interpreter) Run(source string) (object.Object, error) {
+	return i.RunContext(context.Background(), source)
+}
+
+// 执行一段代码，ctx 被取消或超时时中止执行。
+// 代码中的宏在执行前展开
+func (i *Interpreter) RunContext(ctx context.Context, source string) (object.Object, error) {
+	return i.run(ctx, source, "")
+}
+
+// path 是代码文件的绝对路径，不是来自文件的代码为空字符串
+func (i *Interpreter) run(ctx context.Context, source, path string) (object.Object, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Messages: p.Errors()}
+	}
+	evaluator.DefineMacros(program, i.macros)
+	expanded, err := i.eval.ExpandMacros(program, i.macros)
+	if err != nil {
+		return nil, &MacroError{Message: err.Error()}
+	}
+	i.eval.LoadProgram(expanded.(*ast.Program), path)
+	return result(i.eval.EvalContext(ctx, expanded, i.env))
+}
+
+// 执行代码文件，文件中相对路径的 import 以文件所在目录为基准
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	i.env.SetDir(filepath.Dir(abs))
+	return i.run(context.Background(), string(src), abs)
+}
+
+// 调用全局环境中的函数，参数会通过 ToObject 转换
+func (i *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// 调用全局环境中的函数，ctx 被取消或超时时中止执行
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...interface{}) (object.Object, error) {
+	fn, ok := i.env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("function not found: %s", name)
+	}
+	objs := make([]object.Object, len(args))
+	for idx, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objs[idx] = obj
+	}
+	if f, ok := fn.(*object.Function); ok && len(objs) < len(f.Parameters) {
+		return nil, fmt.Errorf("wrong number of arguments to %s. got=%d, want=%d",
+			name, len(objs), len(f.Parameters))
+	}
+	return result(i.eval.ApplyFunctionContext(ctx, fn, objs...))
+}
+
+// 设置全局变量，值会通过 ToObject 转换
+func (i *Interpreter) Set(name string, value interface{}) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+	i.env.Set(name, obj)
+	return nil
+}
+
+// 读取全局变量
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+// 注册一个内置函数，只对当前解释器可见，同名时覆盖默认的内置函数
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.eval.RegisterBuiltin(name, fn)
+}
+
+// 移除一个内置函数，只影响当前解释器
+func (i *Interpreter) RemoveBuiltin(name string) {
+	i.eval.RemoveBuiltin(name)
+}
+
+// 把 object.Error 转换为 go 的 error
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Message: errObj.Message}
+	}
+	return obj, nil
+}
@@ -0,0 +1,283 @@
+package interp
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"monkey/evaluator"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	i := New()
+	result, err := i.Run("let x = 5; x * 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ToGo(result) != int64(10) {
+		t.Errorf("wrong result. got=%v", ToGo(result))
+	}
+	// 全局环境在多次执行之间保留
+	result, err = i.Run("x + 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ToGo(result) != int64(6) {
+		t.Errorf("wrong result. got=%v", ToGo(result))
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	i := New()
+	_, err := i.Run("let = 1;")
+	if _, ok := err.(*ParseError); !ok {
+		t.Errorf("expected *ParseError. got=%T (%v)", err, err)
+	}
+	_, err = i.Run("1 + true")
+	rtErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected *RuntimeError. got=%T (%v)", err, err)
+	}
+	if rtErr.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", rtErr.Message)
+	}
+}
+
+func TestCall(t *testing.T) {
+	i := New()
+	if _, err := i.Run("let add = fn(a, b) { a + b }; let names = fn(h) { h.name };"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := i.Call("add", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ToGo(result) != int64(3) {
+		t.Errorf("wrong result. got=%v", ToGo(result))
+	}
+	result, err = i.Call("names", map[string]interface{}{"name": "monkey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ToGo(result) != "monkey" {
+		t.Errorf("wrong result. got=%v", ToGo(result))
+	}
+	if _, err := i.Call("add", 1); err == nil {
+		t.Errorf("expected error for missing arguments")
+	}
+	if _, err := i.Call("missing"); err == nil {
+		t.Errorf("expected error for unknown function")
+	}
+	if _, err := i.Call("add", 1, true); err == nil {
+		t.Errorf("expected runtime error")
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	i := New()
+	if err := i.Set("config", map[string]interface{}{
+		"name":  "svc",
+		"ports": []int{80, 443},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := i.Run(`let port = config["ports"][1]; config.name + ":" + str(port)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ToGo(result) != "svc:443" {
+		t.Errorf("wrong result. got=%v", ToGo(result))
+	}
+	port, ok := i.Get("port")
+	if !ok || ToGo(port) != int64(443) {
+		t.Errorf("wrong global. got=%v (%t)", port, ok)
+	}
+	if _, ok := i.Get("nope"); ok {
+		t.Errorf("unexpected global nope")
+	}
+	if err := i.Set("bad", struct{}{}); err == nil {
+		t.Errorf("expected conversion error")
+	}
+}
+
+func TestRegisterBuiltinAndStdout(t *testing.T) {
+	var out bytes.Buffer
+	i := New(WithStdout(&out))
+	i.RegisterBuiltin("double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	if _, err := i.Run(`puts(double(21)); puts("hi")`); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "42\nhi\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+	// 注册的内置函数只对当前解释器可见
+	if _, err := New().Run("double(1)"); err == nil {
+		t.Errorf("builtin leaked into another interpreter")
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "lib.mk"), []byte(`export let v = 7;`), 0o644)
+	os.WriteFile(filepath.Join(dir, "main.mk"), []byte(`import "lib.mk" as lib; lib.v`), 0o644)
+	result, err := New().RunFile(filepath.Join(dir, "main.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ToGo(result) != int64(7) {
+		t.Errorf("wrong result. got=%v", ToGo(result))
+	}
+}
+
+func TestMacros(t *testing.T) {
+	i := New()
+	_, err := i.Run(`let unless = macro(cond, then, otherwise) {
+		quote(if (!(unquote(cond))) { unquote(then) } else { unquote(otherwise) });
+	};`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 宏在多次 Run 之间保留
+	result, err := i.Run(`unless(1 > 2, "yes", "no")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ToGo(result) != "yes" {
+		t.Errorf("wrong result. got=%v", ToGo(result))
+	}
+	_, err = i.Run(`unless(true)`)
+	if _, ok := err.(*MacroError); !ok {
+		t.Errorf("expected *MacroError. got=%T (%v)", err, err)
+	}
+
+	// 模块中的宏在导入时展开，且不会泄露到导入方
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "lib.mk"), []byte(`
+		let twice = macro(x) { quote(unquote(x) + unquote(x)) };
+		export let v = twice(21);
+	`), 0o644)
+	os.WriteFile(filepath.Join(dir, "main.mk"), []byte(`import "lib.mk" as lib; lib.v`), 0o644)
+	result, err = New().RunFile(filepath.Join(dir, "main.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ToGo(result) != int64(42) {
+		t.Errorf("wrong result. got=%v", ToGo(result))
+	}
+	os.WriteFile(filepath.Join(dir, "main.mk"), []byte(`import "lib.mk" as lib; twice(1)`), 0o644)
+	if _, err := New().RunFile(filepath.Join(dir, "main.mk")); err == nil {
+		t.Errorf("macro leaked out of module")
+	}
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected interface{}
+	}{
+		{nil, nil},
+		{true, true},
+		{int32(5), int64(5)},
+		{uint8(5), int64(5)},
+		{uint64(math.MaxInt64), int64(math.MaxInt64)},
+		{float32(1.5), 1.5},
+		{"s", "s"},
+		{[]string{"a", "b"}, []interface{}{"a", "b"}},
+		{map[string]int{"a": 1}, map[string]interface{}{"a": int64(1)}},
+	}
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Fatalf("ToObject(%v) error: %s", tt.input, err)
+		}
+		if got := ToGo(obj); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("round trip of %v. got=%#v, want=%#v", tt.input, got, tt.expected)
+		}
+	}
+	if _, err := ToObject(map[int]int{1: 1}); err == nil {
+		t.Errorf("expected error for non-string map key")
+	}
+	if _, err := ToObject(complex(1, 2)); err == nil {
+		t.Errorf("expected error for complex number")
+	}
+	if _, err := ToObject(uint64(math.MaxInt64 + 1)); err == nil {
+		t.Errorf("expected error for uint64 above the INTEGER range")
+	}
+
+	// 不同类型的 key 即使 Inspect 的结果相同也不会合并
+	result, err := New().Run(`{1: "a", "1": "b", true: "c"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[interface{}]interface{}{int64(1): "a", "1": "b", true: "c"}
+	if got := ToGo(result); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong hash. got=%#v, want=%#v", got, expected)
+	}
+}
+
+func TestRemoveBuiltin(t *testing.T) {
+	i := New()
+	i.RemoveBuiltin("puts")
+	if _, err := i.Run(`puts("x")`); err == nil {
+		t.Errorf("expected puts to be unavailable")
+	}
+	if _, err := New(WithStdout(&bytes.Buffer{})).Run(`puts("x")`); err != nil {
+		t.Errorf("puts removed from another interpreter: %s", err)
+	}
+}
+
+func TestStderr(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	i := New(WithStdout(&stdout), WithStderr(&stderr))
+	if _, err := i.Run(`print("out"); eprint("err")`); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "out" || stderr.String() != "err" {
+		t.Errorf("wrong output. stdout=%q, stderr=%q", stdout.String(), stderr.String())
+	}
+}
+
+func TestLimitsAndContext(t *testing.T) {
+	i := New(WithLimits(evaluator.Limits{MaxSteps: 10000}))
+	_, err := i.Run(`let f = fn() { f() }; f()`)
+	if err == nil || err.Error() != "step budget exceeded (max 10000)" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+	// 中止后解释器仍然可用
+	result, err := i.Run(`1 + 1`)
+	if err != nil || ToGo(result) != int64(2) {
+		t.Errorf("interpreter unusable after abort: %v, %v", result, err)
+	}
+
+	i = New(WithLimits(evaluator.Limits{}))
+	if _, err := i.Run(`let spin = fn(n) { if (n == 0) { 0 } else { spin(n - 1) + spin(n - 1) } };`); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = i.CallContext(ctx, "spin", 30)
+	if err == nil || err.Error() != "execution cancelled: context deadline exceeded" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := i.RunContext(ctx, `spin(30)`); err == nil {
+		t.Errorf("expected cancellation error")
+	}
+}
+
+func TestWithCapabilities(t *testing.T) {
+	if _, err := New().Run(`time()`); err == nil {
+		t.Errorf("expected capability error")
+	}
+	if _, err := New(WithCapabilities(evaluator.CapClock)).Run(`time()`); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
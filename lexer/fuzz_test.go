@@ -0,0 +1,33 @@
+package lexer
+
+import (
+	"monkey/internal/fuzzseed"
+	"monkey/token"
+	"testing"
+)
+
+// 任何输入都不能让词法分析 panic，并且在有限的步数内到达 EOF
+func FuzzNextToken(f *testing.F) {
+	seeds, err := fuzzseed.Load(".", "../conformance/testdata")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		l := New(input)
+		for i := 0; ; i++ {
+			if i > len(input)+1 {
+				t.Fatalf("no EOF after %d tokens", i)
+			}
+			tok := l.NextToken()
+			if tok.Line < 1 || tok.Column < 1 {
+				t.Fatalf("invalid position %d:%d for %q", tok.Line, tok.Column, tok.Literal)
+			}
+			if tok.Type == token.EOF {
+				break
+			}
+		}
+	})
+}
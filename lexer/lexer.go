@@ -2,6 +2,9 @@ package lexer
 
 import (
 	"monkey/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Lexer 对象，负责将字符串转换成token
@@ -9,7 +12,10 @@ type Lexer struct {
 	input        string // 要解析的代码字符串
 	position     int    // 当前字符位置
 	readPosition int    // 下一个字符位置
-	ch           byte   // 当前的字符串
+	ch           rune   // 当前的字符（按 UTF-8 解码）
+	line         int    // 当前字符所在的行
+	column       int    // 当前字符所在的列
+	comments     []token.Token
 }
 
 /*
@@ -18,26 +24,47 @@ type Lexer struct {
 	新建一个Lexer对象引用
 */
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
-// 读取下一个字符
+// 读取下一个字符，多字节字符按 rune 整体读取
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		// 离开换行符，进入下一行
+		l.line++
+		l.column = 0
+	}
+	size := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
+	} else if b := l.input[l.readPosition]; b < utf8.RuneSelf {
+		l.ch = rune(b)
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, size = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += size
+	l.column++
 }
 
-// 将Lexer当前的字符串转换为token
+// 将Lexer当前的字符串转换为token，跳过空白和注释
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
 	l.skipWhitespace()
+	line, column := l.line, l.column
+	tok := l.readToken()
+	tok.Line, tok.Column = line, column
+	return tok
+}
+
+// 已经读过的注释，按出现的顺序排列
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -78,11 +105,13 @@ func (l *Lexer) NextToken() token.Token {
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
 	case '[':
-		tok = newToken(token.LBRACKET,l.ch)
+		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
-		tok = newToken(token.RBRACKET,l.ch)
+		tok = newToken(token.RBRACKET, l.ch)
 	case ':':
-		tok = newToken(token.COLON,l.ch)
+		tok = newToken(token.COLON, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -95,8 +124,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -104,7 +132,6 @@ func (l *Lexer) NextToken() token.Token {
 	}
 	l.readChar()
 	return tok
-
 }
 
 func (l *Lexer) readString() string {
@@ -119,13 +146,14 @@ func (l *Lexer) readString() string {
 }
 
 // 创建一个token
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-// 是否是字母，下划线'_'也算做字母了，可以作为变量
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// 是否是字母，下划线'_'也算做字母了，可以作为变量。支持中文等 Unicode 字母
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
 // 读取一个Identifier的字符串
@@ -137,32 +165,58 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-// 跳过空白字符
+// 跳过空白字符和注释
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+			l.readChar()
+		}
+		if l.ch != '/' || l.peekChar() != '/' {
+			return
+		}
+		l.readComment()
+	}
+}
+
+// 读取 // 开始的注释，直到行尾
+func (l *Lexer) readComment() {
+	tok := token.Token{Type: token.COMMENT, Line: l.line, Column: l.column}
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	tok.Literal = strings.TrimRight(l.input[position:l.position], " \t\r")
+	l.comments = append(l.comments, tok)
 }
 
-// 读取一个数字
-func (l *Lexer) readNumber() string {
+// 读取整数或浮点数。小数点后必须紧跟数字，如 1.5，这样 1.x 仍然是成员访问
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		for isDigit(l.ch) {
+			l.readChar()
+		}
+	}
+	return l.input[position:l.position], tokenType
 }
 
 // 是否数字字符
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
 // 查看下一个字符（但不移动）
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
 	}
 }
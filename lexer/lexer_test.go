@@ -139,3 +139,107 @@ func TestNextToken(t *testing.T) {
 	}
 
 }
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `let 名字 = "猴子"; 名字 == "猴子"; café`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "名字"},
+		{token.ASSIGN, "="},
+		{token.STRING, "猴子"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "名字"},
+		{token.EQ, "=="},
+		{token.STRING, "猴子"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "café"},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `5 1.25 10.x 3.`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "1.25"},
+		{token.INT, "10"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "3"},
+		{token.DOT, "."},
+		{token.EOF, ""},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestPositionsAndComments(t *testing.T) {
+	input := "let x = 5; // five\n// whole line\n  x / 2 //\n\"s\""
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		line, column    int
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "x", 1, 5},
+		{token.ASSIGN, "=", 1, 7},
+		{token.INT, "5", 1, 9},
+		{token.SEMICOLON, ";", 1, 10},
+		{token.IDENT, "x", 3, 3},
+		{token.SLASH, "/", 3, 5},
+		{token.INT, "2", 3, 7},
+		{token.STRING, "s", 4, 1},
+		{token.EOF, "", 4, 4},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Line != tt.line || tok.Column != tt.column {
+			t.Errorf("tests[%d] - wrong position for %q. expected=%d:%d, got=%d:%d",
+				i, tok.Literal, tt.line, tt.column, tok.Line, tok.Column)
+		}
+	}
+
+	comments := l.Comments()
+	expected := []token.Token{
+		{Type: token.COMMENT, Literal: "// five", Line: 1, Column: 12},
+		{Type: token.COMMENT, Literal: "// whole line", Line: 2, Column: 1},
+		{Type: token.COMMENT, Literal: "//", Line: 3, Column: 9},
+	}
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments. got=%d", len(comments))
+	}
+	for i, c := range comments {
+		if c != expected[i] {
+			t.Errorf("comments[%d] wrong. expected=%+v, got=%+v", i, expected[i], c)
+		}
+	}
+}
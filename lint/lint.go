@@ -0,0 +1,149 @@
+// lint 包对 monkey 程序做静态检查，找出运行时才会暴露的常见错误
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"sort"
+	"strings"
+)
+
+// 检查规则的名字
+const (
+	RuleUndefined       = "undefined"
+	RuleUnused          = "unused"
+	RuleShadowedBuiltin = "shadowed-builtin"
+	RuleUnreachable     = "unreachable"
+	RuleArity           = "arity"
+)
+
+// 一条检查结果
+type Diagnostic struct {
+	Line    int
+	Column  int
+	Rule    string
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", d.Line, d.Column, d.Message, d.Rule)
+}
+
+// 检查程序，结果按位置排序
+func Lint(program *ast.Program) []Diagnostic {
+	l := &linter{builtins: map[string]bool{"quote": true, "unquote": true}}
+	for _, name := range evaluator.BuiltinNames() {
+		l.builtins[name] = true
+	}
+	l.res = Resolve(program)
+	for _, s := range l.res.Scopes {
+		for _, b := range s.Order {
+			if l.builtins[b.Name.Value] {
+				l.report(b.Name, RuleShadowedBuiltin, "%s shadows the builtin function of the same name", b.Name.Value)
+			}
+		}
+	}
+	ast.Walk(&visitor{l: l}, program)
+	for _, ident := range l.res.Idents {
+		if l.res.Refs[ident] == nil && !l.builtins[ident.Value] {
+			l.report(ident, RuleUndefined, "undefined: %s", ident.Value)
+		}
+	}
+	l.reportUnused()
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i], l.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.diagnostics
+}
+
+type linter struct {
+	builtins    map[string]bool
+	res         *Resolution
+	diagnostics []Diagnostic
+}
+
+func (l *linter) report(node ast.Node, rule, format string, a ...interface{}) {
+	line, column := ast.Pos(node)
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Line:    line,
+		Column:  column,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// 报告没有被使用的 let 绑定，以下划线开头的名字除外
+func (l *linter) reportUnused() {
+	for _, s := range l.res.Scopes {
+		for _, b := range s.Order {
+			if b.Kind == LetBinding && !b.Used && !strings.HasPrefix(b.Name.Value, "_") {
+				l.report(b.Name, RuleUnused, "%s declared and not used", b.Name.Value)
+			}
+		}
+	}
+}
+
+// 检查不可达的代码和内置函数调用的参数个数，名字已经由 Resolve 解析
+type visitor struct {
+	l *linter
+}
+
+func (v *visitor) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Program:
+		v.checkUnreachable(node.Statements)
+	case *ast.BlockStatement:
+		v.checkUnreachable(node.Statements)
+	case *ast.CallExpression:
+		if isQuote(node) {
+			for _, arg := range unquoted(node) {
+				ast.Walk(v, arg)
+			}
+			return nil
+		}
+		v.checkArity(node)
+	}
+	return v
+}
+
+// return 之后的语句不会被执行
+func (v *visitor) checkUnreachable(statements []ast.Statement) {
+	for i, stmt := range statements {
+		if _, ok := stmt.(*ast.ReturnStatement); ok && i+1 < len(statements) {
+			v.l.report(statements[i+1], RuleUnreachable, "unreachable code")
+			return
+		}
+	}
+}
+
+// 检查内置函数调用的参数个数
+func (v *visitor) checkArity(call *ast.CallExpression) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok || v.l.res.Refs[ident] != nil {
+		return
+	}
+	arity, ok := evaluator.BuiltinArity(ident.Value)
+	if !ok {
+		return
+	}
+	n := len(call.Arguments)
+	if n >= arity.Min && (arity.Max < 0 || n <= arity.Max) {
+		return
+	}
+	var want string
+	switch {
+	case arity.Max < 0:
+		want = fmt.Sprintf("at least %d", arity.Min)
+	case arity.Min == arity.Max:
+		want = fmt.Sprintf("%d", arity.Min)
+	default:
+		want = fmt.Sprintf("%d to %d", arity.Min, arity.Max)
+	}
+	v.l.report(call, RuleArity, "%s called with %d arguments, want %s", ident.Value, n, want)
+}
@@ -0,0 +1,120 @@
+package lint
+
+import (
+	"fmt"
+	"monkey/lexer"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func lintSource(t *testing.T, input string) []string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	result := []string{}
+	for _, d := range Lint(program) {
+		result = append(result, d.String())
+	}
+	return result
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x = 1; puts(x);`, []string{}},
+		{`let x = 1;`, []string{"1:5: x declared and not used (unused)"}},
+		{`let _x = 1;`, []string{}},
+		{`export let x = 1;`, []string{}},
+		{`puts(y);`, []string{"1:6: undefined: y (undefined)"}},
+		{`let x = x + 1;`, []string{
+			"1:5: x declared and not used (unused)",
+			"1:9: undefined: x (undefined)",
+		}},
+		{`let len = fn(x) { x }; len(1);`, []string{
+			"1:5: len shadows the builtin function of the same name (shadowed-builtin)",
+		}},
+		{`let f = fn(puts) { puts }; f(1);`, []string{
+			"1:12: puts shadows the builtin function of the same name (shadowed-builtin)",
+		}},
+		{"let f = fn() {\n  return 1;\n  puts(2);\n  puts(3);\n};\nf();", []string{
+			"3:3: unreachable code (unreachable)",
+		}},
+		{`len(1, 2); push([1]); puts(); printf(); reduce([1], fn(a, b) { a }, 0, 1);`, []string{
+			"1:1: len called with 2 arguments, want 1 (arity)",
+			"1:12: push called with 1 arguments, want 2 (arity)",
+			"1:31: printf called with 0 arguments, want at least 1 (arity)",
+			"1:41: reduce called with 4 arguments, want 2 to 3 (arity)",
+		}},
+		// 递归和引用后面定义的函数
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; let a = fn() { b() }; let b = fn() { 1 }; puts(fib(10), a());`,
+			[]string{}},
+		// 直接引用后面定义的名字是错误的
+		{`puts(later); let later = 1;`, []string{
+			"1:6: undefined: later (undefined)",
+			"1:18: later declared and not used (unused)",
+		}},
+		// if 的代码块不创建新的作用域
+		{`if (true) { let y = 1; } puts(y);`, []string{}},
+		// 函数参数和局部变量
+		{`let f = fn(a, b) { let c = a; c }; f(1, 2);`, []string{}},
+		{`let f = fn() { let unused = 1; 2 }; f();`, []string{"1:20: unused declared and not used (unused)"}},
+		{`import "lib/m.mk"; import "x.mk" as y; import { p, q } from "z.mk"; puts(m.v, y, p);`, []string{}},
+		{`puts(o.name);`, []string{"1:6: undefined: o (undefined)"}},
+		{`let m = macro(a) { quote(unquote(a) + b) }; m(1);`, []string{}},
+		{`let m = macro(a) { quote(unquote(c)) }; m(1);`, []string{
+			"1:34: undefined: c (undefined)",
+		}},
+		{`let f = fn(x) { x }; let len = 1; f(len(1, 2));`, []string{
+			"1:26: len shadows the builtin function of the same name (shadowed-builtin)",
+		}},
+	}
+	for _, tt := range tests {
+		got := lintSource(t, tt.input)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("wrong diagnostics for %q.\ngot= %q\nwant=%q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+// 每个名字解析到定义它的绑定，函数体可以引用后面定义的名字，quote 中只解析 unquote 的参数
+func TestResolve(t *testing.T) {
+	input := `let f = fn(x) { g(x) }; let g = fn(y) { y }; quote(h + unquote(f)); {"k": 1}.k`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	res := Resolve(program)
+	if len(res.Scopes) != 3 {
+		t.Fatalf("wrong number of scopes. got=%d, want=3", len(res.Scopes))
+	}
+	got := []string{}
+	for _, ident := range res.Idents {
+		b := res.Refs[ident]
+		if b == nil {
+			got = append(got, ident.Value+" -> undefined")
+			continue
+		}
+		line, column := ident.Token.Line, ident.Token.Column
+		defLine, defColumn := b.Name.Token.Line, b.Name.Token.Column
+		got = append(got, fmt.Sprintf("%s %d:%d -> %d:%d", ident.Value, line, column, defLine, defColumn))
+	}
+	expected := []string{
+		"x 1:12 -> 1:12",
+		"f 1:5 -> 1:5",
+		"y 1:36 -> 1:36",
+		"g 1:29 -> 1:29",
+		"f 1:64 -> 1:5",
+		"g 1:17 -> 1:29",
+		"x 1:19 -> 1:12",
+		"y 1:41 -> 1:36",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong resolution.\ngot:  %v\nwant: %v", got, expected)
+	}
+}
@@ -0,0 +1,195 @@
+package lint
+
+import (
+	"monkey/ast"
+	"path/filepath"
+	"strings"
+)
+
+// 绑定的种类
+type BindingKind int
+
+const (
+	LetBinding BindingKind = iota
+	ParamBinding
+	ImportBinding
+)
+
+// 一个名字的绑定：let 绑定、函数参数或 import 引入的名字
+type Binding struct {
+	Name  *ast.Identifier
+	Kind  BindingKind
+	Value ast.Expression // let 绑定的值
+	Used  bool           // 被引用或导出过
+}
+
+// 作用域，对应求值时的一个 object.Environment：程序顶层或一次函数调用。
+// if 的代码块不会创建新的环境，因此也不是单独的作用域
+type Scope struct {
+	Parent   *Scope
+	Node     ast.Node // *ast.Program、*ast.FunctionLiteral 或 *ast.MacroLiteral
+	Bindings map[string]*Binding
+	Order    []*Binding // 按声明的顺序
+}
+
+// 查找名字对应的绑定，找不到时返回 nil
+func (s *Scope) Lookup(name string) *Binding {
+	for ; s != nil; s = s.Parent {
+		if b, ok := s.Bindings[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// 名字解析的结果
+type Resolution struct {
+	Scopes []*Scope                     // 按创建的顺序，第一个是程序顶层
+	Idents []*ast.Identifier            // 源码中出现的名字，包括定义处的名字
+	Refs   map[*ast.Identifier]*Binding // 名字对应的绑定，定义处的名字对应自己，未定义的名字不在其中
+}
+
+// 解析程序中所有名字的绑定，规则和求值时的环境相同。
+// 语法树可以不完整（有语法错误时），缺失的节点被忽略
+func Resolve(program *ast.Program) *Resolution {
+	r := &Resolution{Refs: map[*ast.Identifier]*Binding{}}
+	pending := []func(){}
+	ast.Walk(&resolver{r: r, scope: r.newScope(nil, program), pending: &pending}, program)
+	// 函数体在外层作用域的所有绑定都声明之后再解析，
+	// 这样递归调用和引用后面定义的函数都能被正确解析
+	for len(pending) > 0 {
+		fn := pending[0]
+		pending = pending[1:]
+		fn()
+	}
+	return r
+}
+
+func (r *Resolution) newScope(parent *Scope, node ast.Node) *Scope {
+	s := &Scope{Parent: parent, Node: node, Bindings: map[string]*Binding{}}
+	r.Scopes = append(r.Scopes, s)
+	return s
+}
+
+func (r *Resolution) declare(s *Scope, name *ast.Identifier, kind BindingKind, value ast.Expression) {
+	b := &Binding{Name: name, Kind: kind, Value: value}
+	s.Bindings[name.Value] = b
+	s.Order = append(s.Order, b)
+	r.Refs[name] = b
+	r.Idents = append(r.Idents, name)
+}
+
+type resolver struct {
+	r       *Resolution
+	scope   *Scope
+	pending *[]func()
+}
+
+func (v *resolver) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		// 先解析右侧，此时名字还没有绑定
+		if node.Value != nil {
+			ast.Walk(v, node.Value)
+		}
+		if node.Name != nil {
+			v.r.declare(v.scope, node.Name, LetBinding, node.Value)
+		}
+		return nil
+	case *ast.ExportStatement:
+		if node.Statement == nil {
+			return nil
+		}
+		ast.Walk(v, node.Statement)
+		// 导出的绑定由导入方使用
+		if b, ok := v.r.Refs[node.Statement.Name]; ok {
+			b.Used = true
+		}
+		return nil
+	case *ast.ImportStatement:
+		switch {
+		case len(node.Names) > 0:
+			for _, name := range node.Names {
+				v.r.declare(v.scope, name, ImportBinding, nil)
+			}
+		case node.Alias != nil:
+			v.r.declare(v.scope, node.Alias, ImportBinding, nil)
+		case node.Path != nil:
+			// 没有别名时模块绑定到文件名，定义的位置是路径字符串。
+			// 这个名字不在源码中出现，因此不记录到 Idents 和 Refs
+			base := filepath.Base(node.Path.Value)
+			name := &ast.Identifier{Token: node.Path.Token, Value: strings.TrimSuffix(base, filepath.Ext(base))}
+			b := &Binding{Name: name, Kind: ImportBinding}
+			v.scope.Bindings[name.Value] = b
+			v.scope.Order = append(v.scope.Order, b)
+		}
+		return nil
+	case *ast.Identifier:
+		v.r.Idents = append(v.r.Idents, node)
+		if b := v.scope.Lookup(node.Value); b != nil {
+			b.Used = true
+			v.r.Refs[node] = b
+		}
+		return nil
+	case *ast.MemberExpression:
+		// 属性名不是变量引用
+		if node.Object != nil {
+			ast.Walk(v, node.Object)
+		}
+		return nil
+	case *ast.FunctionLiteral:
+		v.function(node, node.Parameters, node.Body)
+		return nil
+	case *ast.MacroLiteral:
+		v.function(node, node.Parameters, node.Body)
+		return nil
+	case *ast.CallExpression:
+		if isQuote(node) {
+			for _, arg := range unquoted(node) {
+				ast.Walk(v, arg)
+			}
+			return nil
+		}
+	}
+	return v
+}
+
+// 函数体使用新的作用域，参数绑定在其中。函数体推迟到外层解析完后再解析
+func (v *resolver) function(node ast.Node, params []*ast.Identifier, body *ast.BlockStatement) {
+	s := v.r.newScope(v.scope, node)
+	for _, param := range params {
+		if param != nil {
+			v.r.declare(s, param, ParamBinding, nil)
+		}
+	}
+	if body == nil {
+		return
+	}
+	*v.pending = append(*v.pending, func() {
+		ast.Walk(&resolver{r: v.r, scope: s, pending: v.pending}, body)
+	})
+}
+
+func isQuote(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "quote"
+}
+
+// quote 的参数不会被求值，只有其中 unquote 的参数会被求值
+func unquoted(call *ast.CallExpression) []ast.Expression {
+	args := []ast.Expression{}
+	for _, arg := range call.Arguments {
+		ast.Inspect(arg, func(node ast.Node) bool {
+			unquote, ok := node.(*ast.CallExpression)
+			if !ok {
+				return true
+			}
+			if ident, ok := unquote.Function.(*ast.Identifier); ok && ident.Value == "unquote" {
+				args = append(args, unquote.Arguments...)
+				return false
+			}
+			return true
+		})
+	}
+	return args
+}
@@ -0,0 +1,504 @@
+package lsp
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// 打开的文档和对它的分析结果。文档每次修改后都重新分析
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	program *ast.Program
+	errors  []parser.Error
+	lints   []lint.Diagnostic
+
+	scopes []*lint.Scope
+	idents []*ast.Identifier                 // 源码中出现的名字，按位置排序
+	refs   map[*ast.Identifier]*lint.Binding // 名字对应的定义，定义处的名字对应自己
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{
+		uri:   uri,
+		text:  text,
+		lines: strings.Split(text, "\n"),
+	}
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	d.errors = p.ErrorPositions()
+	// 有语法错误时语法树不完整，检查结果没有意义
+	if len(d.errors) == 0 {
+		d.lints = lint.Lint(d.program)
+	}
+	d.resolve()
+	return d
+}
+
+// 解析所有名字的定义，名字按位置排序
+func (d *document) resolve() {
+	res := lint.Resolve(d.program)
+	d.scopes = res.Scopes
+	d.refs = res.Refs
+	d.idents = append([]*ast.Identifier{}, res.Idents...)
+	sort.SliceStable(d.idents, func(i, j int) bool {
+		return ast.PositionOf(d.idents[i]).Before(ast.PositionOf(d.idents[j]))
+	})
+}
+
+// 作用域在源码中的范围。end 为零值表示一直到文件末尾
+func scopeRange(s *lint.Scope) (start, end ast.Position) {
+	var body *ast.BlockStatement
+	switch node := s.Node.(type) {
+	case *ast.FunctionLiteral:
+		body = node.Body
+	case *ast.MacroLiteral:
+		body = node.Body
+	default:
+		return ast.Position{Line: 1, Column: 1}, ast.Position{}
+	}
+	if body != nil && body.End.Line > 0 {
+		end = ast.TokenPosition(body.End)
+	}
+	return ast.PositionOf(s.Node), end
+}
+
+func scopeContains(s *lint.Scope, pos ast.Position) bool {
+	start, end := scopeRange(s)
+	if pos.Before(start) {
+		return false
+	}
+	return end == (ast.Position{}) || !end.Before(pos)
+}
+
+// 位置上的名字，没有时返回 nil
+func (d *document) identAt(pos ast.Position) *ast.Identifier {
+	for _, ident := range d.idents {
+		start := ast.PositionOf(ident)
+		end := ast.Position{Line: start.Line, Column: start.Column + len([]rune(ident.Value))}
+		if !pos.Before(start) && pos.Before(end) {
+			return ident
+		}
+		// 光标在名字的末尾时也算
+		if pos == end {
+			return ident
+		}
+	}
+	return nil
+}
+
+// 包含位置的最内层作用域
+func (d *document) scopeAt(pos ast.Position) *lint.Scope {
+	var innermost *lint.Scope
+	var innermostStart ast.Position
+	for _, s := range d.scopes {
+		start, _ := scopeRange(s)
+		if scopeContains(s, pos) && (innermost == nil || innermostStart.Before(start)) {
+			innermost, innermostStart = s, start
+		}
+	}
+	return innermost
+}
+
+// 位置上可以使用的名字。最内层作用域只包含位置之前定义的名字，
+// 外层作用域的名字都可以使用，因为函数体在外层求值完之后才会执行
+func (d *document) namesAt(pos ast.Position) []*lint.Binding {
+	seen := map[string]bool{}
+	result := []*lint.Binding{}
+	innermost := true
+	for s := d.scopeAt(pos); s != nil; s = s.Parent {
+		for _, def := range s.Order {
+			if seen[def.Name.Value] {
+				continue
+			}
+			if innermost && !ast.PositionOf(def.Name).Before(pos) {
+				continue
+			}
+			seen[def.Name.Value] = true
+			result = append(result, def)
+		}
+		innermost = false
+	}
+	return result
+}
+
+// 推断表达式的值的类型，无法推断时返回空字符串
+func (d *document) kindOf(exp ast.Expression, depth int) object.ObjectType {
+	if depth > 8 {
+		return ""
+	}
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ
+	case *ast.FloatLiteral:
+		return object.FLOAT_OBJ
+	case *ast.StringLiteral:
+		return object.STRING_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ
+	case *ast.HashLiteral:
+		return object.HASH_OBJ
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ
+	case *ast.MacroLiteral:
+		return object.MACRO_OBJ
+	case *ast.Identifier:
+		if def := d.refs[exp]; def != nil {
+			if def.Kind == lint.LetBinding && def.Value != nil {
+				return d.kindOf(def.Value, depth+1)
+			}
+			return ""
+		}
+		if _, ok := evaluator.BuiltinArity(exp.Value); ok {
+			return object.BUILTIN_OBJ
+		}
+	case *ast.PrefixExpression:
+		if exp.Operator == "!" {
+			return object.BOOLEAN_OBJ
+		}
+		if kind := d.kindOf(exp.Right, depth+1); kind == object.INTEGER_OBJ || kind == object.FLOAT_OBJ {
+			return kind
+		}
+	case *ast.InfixExpression:
+		switch exp.Operator {
+		case "<", ">", "==", "!=":
+			return object.BOOLEAN_OBJ
+		}
+		left, right := d.kindOf(exp.Left, depth+1), d.kindOf(exp.Right, depth+1)
+		switch {
+		case left == object.INTEGER_OBJ && right == object.INTEGER_OBJ:
+			return object.INTEGER_OBJ
+		case isNumberKind(left) && isNumberKind(right):
+			return object.FLOAT_OBJ
+		case exp.Operator == "+" && left == object.STRING_OBJ && right == object.STRING_OBJ:
+			return object.STRING_OBJ
+		}
+	case *ast.IfExpression:
+		consequence := d.kindOfBlock(exp.Consequence, depth+1)
+		if exp.Alternative == nil {
+			return ""
+		}
+		if alternative := d.kindOfBlock(exp.Alternative, depth+1); consequence == alternative {
+			return consequence
+		}
+	case *ast.CallExpression:
+		ident, ok := exp.Function.(*ast.Identifier)
+		if !ok {
+			return ""
+		}
+		if def := d.refs[ident]; def != nil {
+			if fn, ok := def.Value.(*ast.FunctionLiteral); ok && def.Kind == lint.LetBinding {
+				return d.kindOfBlock(fn.Body, depth+1)
+			}
+			return ""
+		}
+		return builtinResultKinds[ident.Value]
+	}
+	return ""
+}
+
+func isNumberKind(kind object.ObjectType) bool {
+	return kind == object.INTEGER_OBJ || kind == object.FLOAT_OBJ
+}
+
+// 代码块的值是最后一条表达式语句的值
+func (d *document) kindOfBlock(block *ast.BlockStatement, depth int) object.ObjectType {
+	if block == nil || len(block.Statements) == 0 {
+		return ""
+	}
+	switch stmt := block.Statements[len(block.Statements)-1].(type) {
+	case *ast.ExpressionStatement:
+		return d.kindOf(stmt.Expression, depth)
+	case *ast.ReturnStatement:
+		return d.kindOf(stmt.ReturnValue, depth)
+	}
+	return ""
+}
+
+// 返回值类型固定的内置函数
+var builtinResultKinds = map[string]object.ObjectType{}
+
+func init() {
+	kinds := map[object.ObjectType][]string{
+		object.INTEGER_OBJ: {"len", "index_of", "ord", "int", "time", "time_ms", "random"},
+		object.FLOAT_OBJ:   {"float"},
+		object.STRING_OBJ: {"str", "type", "join", "trim", "trim_left", "trim_right", "upper", "lower",
+			"replace", "repeat", "substr", "format", "chr", "json_stringify", "read_file",
+			"path_join", "path_base", "path_dir", "path_ext"},
+		object.BOOLEAN_OBJ: {"bool", "contains", "starts_with", "ends_with", "is_integer", "is_float",
+			"is_string", "is_bool", "is_array", "is_hash", "is_null", "is_function", "any", "all", "exists"},
+		object.ARRAY_OBJ: {"rest", "push", "map", "filter", "sort", "sort_by", "split", "chars", "bytes", "list_dir"},
+		object.NULL_OBJ:  {"puts", "print", "printf", "eprint", "write_file", "assert", "assert_eq"},
+	}
+	for kind, names := range kinds {
+		for _, name := range names {
+			builtinResultKinds[name] = kind
+		}
+	}
+}
+
+// 悬停时显示的说明
+func (d *document) describe(ident *ast.Identifier) string {
+	def := d.refs[ident]
+	if def == nil {
+		arity, ok := evaluator.BuiltinArity(ident.Value)
+		if !ok {
+			return ""
+		}
+		text := fmt.Sprintf("(builtin) %s: BUILTIN, takes %s", ident.Value, describeArity(arity))
+		if kind := builtinResultKinds[ident.Value]; kind != "" {
+			text += ", returns " + string(kind)
+		}
+		return text
+	}
+	return d.describeDefinition(def)
+}
+
+func (d *document) describeDefinition(def *lint.Binding) string {
+	switch def.Kind {
+	case lint.ParamBinding:
+		return "(parameter) " + def.Name.Value
+	case lint.ImportBinding:
+		return "(import) " + def.Name.Value
+	}
+	switch value := def.Value.(type) {
+	case *ast.FunctionLiteral:
+		text := fmt.Sprintf("let %s = fn(%s)", def.Name.Value, joinIdentifiers(value.Parameters))
+		if kind := d.kindOfBlock(value.Body, 0); kind != "" {
+			text += ": " + string(kind)
+		}
+		return text
+	case *ast.MacroLiteral:
+		return fmt.Sprintf("let %s = macro(%s)", def.Name.Value, joinIdentifiers(value.Parameters))
+	}
+	if kind := d.kindOf(def.Value, 0); kind != "" {
+		return fmt.Sprintf("let %s: %s", def.Name.Value, kind)
+	}
+	return "let " + def.Name.Value
+}
+
+func describeArity(arity evaluator.Arity) string {
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 argument"
+		}
+		return fmt.Sprintf("%d arguments", n)
+	}
+	switch {
+	case arity.Max < 0:
+		return "at least " + plural(arity.Min)
+	case arity.Min == arity.Max:
+		return plural(arity.Min)
+	default:
+		return fmt.Sprintf("%d to %s", arity.Min, plural(arity.Max))
+	}
+}
+
+func joinIdentifiers(idents []*ast.Identifier) string {
+	names := make([]string, 0, len(idents))
+	for _, ident := range idents {
+		if ident != nil {
+			names = append(names, ident.Value)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// 文档中的符号：顶层的 let 绑定，函数中的 let 绑定作为它的子符号
+func (d *document) symbols() []DocumentSymbol {
+	return d.symbolsIn(d.program.Statements)
+}
+
+func (d *document) symbolsIn(statements []ast.Statement) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, stmt := range statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Statement
+		}
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if stmt.Name != nil {
+				symbols = append(symbols, d.letSymbol(stmt))
+			}
+		case *ast.ImportStatement:
+			names := stmt.Names
+			if stmt.Alias != nil {
+				names = []*ast.Identifier{stmt.Alias}
+			}
+			for _, name := range names {
+				if name != nil {
+					r := d.identRange(name)
+					symbols = append(symbols, DocumentSymbol{
+						Name: name.Value, Kind: SymbolModule, Range: r, SelectionRange: r,
+					})
+				}
+			}
+		case *ast.ExpressionStatement:
+			// if 的代码块中的 let 绑定属于外层
+			if exp, ok := stmt.Expression.(*ast.IfExpression); ok {
+				if exp.Consequence != nil {
+					symbols = append(symbols, d.symbolsIn(exp.Consequence.Statements)...)
+				}
+				if exp.Alternative != nil {
+					symbols = append(symbols, d.symbolsIn(exp.Alternative.Statements)...)
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+func (d *document) letSymbol(stmt *ast.LetStatement) DocumentSymbol {
+	symbol := DocumentSymbol{
+		Name:           stmt.Name.Value,
+		Kind:           SymbolVariable,
+		SelectionRange: d.identRange(stmt.Name),
+	}
+	var body *ast.BlockStatement
+	switch value := stmt.Value.(type) {
+	case *ast.FunctionLiteral:
+		symbol.Kind = SymbolFunction
+		symbol.Detail = "fn(" + joinIdentifiers(value.Parameters) + ")"
+		body = value.Body
+	case *ast.MacroLiteral:
+		symbol.Kind = SymbolFunction
+		symbol.Detail = "macro(" + joinIdentifiers(value.Parameters) + ")"
+		body = value.Body
+	default:
+		symbol.Detail = string(d.kindOf(stmt.Value, 0))
+	}
+	end := d.endOf(stmt)
+	symbol.Range = Range{Start: d.toLSP(ast.PositionOf(stmt)), End: d.toLSP(end)}
+	if body != nil {
+		symbol.Children = d.symbolsIn(body.Statements)
+	}
+	return symbol
+}
+
+// 语句结束的位置，即其中最后一个 token 之后的位置
+func (d *document) endOf(node ast.Node) ast.Position {
+	end := ast.PositionOf(node)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		var p ast.Position
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			p = ast.Position{Line: n.End.Line, Column: n.End.Column + 1}
+		case *ast.StringLiteral:
+			p = ast.Position{Line: n.Token.Line, Column: n.Token.Column + len([]rune(n.Value)) + 2}
+		default:
+			start := ast.PositionOf(n)
+			p = ast.Position{Line: start.Line, Column: start.Column + len([]rune(n.TokenLiteral()))}
+		}
+		if end.Before(p) {
+			end = p
+		}
+		return true
+	})
+	return end
+}
+
+// 把字符列转换为 LSP 使用的 UTF-16 列
+func (d *document) toLSP(p ast.Position) Position {
+	line := p.Line - 1
+	if line < 0 {
+		return Position{}
+	}
+	if line >= len(d.lines) {
+		return Position{Line: line}
+	}
+	runes := []rune(d.lines[line])
+	n := p.Column - 1
+	if n > len(runes) {
+		n = len(runes)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return Position{Line: line, Character: len(utf16.Encode(runes[:n]))}
+}
+
+// 把 LSP 的位置转换为字符列
+func (d *document) fromLSP(p Position) ast.Position {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return ast.Position{Line: p.Line + 1, Column: p.Character + 1}
+	}
+	units := 0
+	column := 1
+	for _, r := range d.lines[p.Line] {
+		if units >= p.Character {
+			break
+		}
+		units += len(utf16.Encode([]rune{r}))
+		column++
+	}
+	return ast.Position{Line: p.Line + 1, Column: column}
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+	start := ast.PositionOf(ident)
+	length := len([]rune(ident.Value))
+	if ident.Token.Type == token.STRING {
+		// import 路径的引号
+		length = len([]rune(ident.Token.Literal)) + 2
+	}
+	return Range{Start: d.toLSP(start), End: d.toLSP(ast.Position{Line: start.Line, Column: start.Column + length})}
+}
+
+// 从 pos 开始的一个单词的范围，不是单词时取一个字符，用于只有起始位置的诊断
+func (d *document) wordRange(pos ast.Position) Range {
+	end := ast.Position{Line: pos.Line, Column: pos.Column + 1}
+	if pos.Line >= 1 && pos.Line <= len(d.lines) {
+		runes := []rune(d.lines[pos.Line-1])
+		i := pos.Column - 1
+		for i >= 0 && i < len(runes) && isWordChar(runes[i]) {
+			i++
+		}
+		if i > pos.Column-1 {
+			end.Column = i + 1
+		}
+	}
+	return Range{Start: d.toLSP(pos), End: d.toLSP(end)}
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9'
+}
+
+// 语法错误和检查结果
+func (d *document) diagnostics() []Diagnostic {
+	result := []Diagnostic{}
+	for _, e := range d.errors {
+		result = append(result, Diagnostic{
+			Range:    d.wordRange(ast.Position{Line: e.Line, Column: e.Column}),
+			Severity: SeverityError,
+			Source:   "monkey",
+			Message:  e.Message,
+		})
+	}
+	for _, l := range d.lints {
+		result = append(result, Diagnostic{
+			Range:    d.wordRange(ast.Position{Line: l.Line, Column: l.Column}),
+			Severity: SeverityWarning,
+			Code:     l.Rule,
+			Source:   "monkey lint",
+			Message:  l.Message,
+		})
+	}
+	return result
+}
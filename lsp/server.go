@@ -0,0 +1,303 @@
+// lsp 包实现 monkey 的 Language Server Protocol 服务器，通过标准输入输出和编辑器通信。
+// 支持语法错误和检查结果的诊断、悬停显示值的类型、跳转到定义、文档符号、补全和格式化
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/formatter"
+	"monkey/lint"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 没有收到 shutdown 请求就收到了 exit 通知，按协议进程应以非零状态退出
+var ErrExitWithoutShutdown = errors.New("exit notification received before shutdown")
+
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*document
+	shutdown  bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]*document{},
+	}
+}
+
+// 处理消息直到收到 exit 通知或输入结束
+func (s *Server) Run() error {
+	for {
+		data, err := s.readMessage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(data, &req); err != nil {
+			if err := s.replyError(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+// 读取一条消息。消息由 HTTP 风格的头部和 JSON 内容组成，头部中的 Content-Length 是内容的长度
+func (s *Server) readMessage() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header %q", header.Get("Content-Length"))
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *Server) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}) error {
+	return s.write(&response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) replyError(id *json.RawMessage, code int, message string) error {
+	return s.write(&errorResponse{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: message}})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// 处理一条请求或通知。请求必须回复，未知的通知直接忽略
+func (s *Server) handle(req *request) error {
+	result, err := s.dispatch(req)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		var rpcErr *responseError
+		if errors.As(err, &rpcErr) {
+			return s.replyError(req.ID, rpcErr.Code, rpcErr.Message)
+		}
+		return s.replyError(req.ID, codeInvalidParams, err.Error())
+	}
+	return s.reply(req.ID, result)
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+func (s *Server) dispatch(req *request) (interface{}, error) {
+	switch req.Method {
+	case "initialize":
+		return s.initialize()
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		// 使用全量同步，最后一次修改就是文档的全部内容
+		if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.notify("textDocument/publishDiagnostics",
+			&PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/hover":
+		return withPosition(s, req, s.hover)
+	case "textDocument/definition":
+		return withPosition(s, req, s.definition)
+	case "textDocument/completion":
+		return withPosition(s, req, s.completion)
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.symbols(), nil
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return s.format(d), nil
+	}
+	if req.ID != nil && !strings.HasPrefix(req.Method, "$/") {
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	return nil, nil
+}
+
+func (s *Server) initialize() (interface{}, error) {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":           1, // 全量同步
+			"hoverProvider":              true,
+			"definitionProvider":         true,
+			"documentSymbolProvider":     true,
+			"completionProvider":         map[string]interface{}{},
+			"documentFormattingProvider": true,
+		},
+		"serverInfo": map[string]string{"name": "monkey"},
+	}, nil
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	d, ok := s.documents[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "unknown document: " + uri}
+	}
+	return d, nil
+}
+
+// 文档打开或修改后重新分析，并发布诊断
+func (s *Server) update(uri, text string) error {
+	d := newDocument(uri, text)
+	s.documents[uri] = d
+	return s.notify("textDocument/publishDiagnostics",
+		&PublishDiagnosticsParams{URI: uri, Diagnostics: d.diagnostics()})
+}
+
+func withPosition(s *Server, req *request, fn func(*document, ast.Position) interface{}) (interface{}, error) {
+	var params TextDocumentPositionParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, err
+	}
+	d, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return fn(d, d.fromLSP(params.Position)), nil
+}
+
+func (s *Server) hover(d *document, pos ast.Position) interface{} {
+	ident := d.identAt(pos)
+	if ident == nil {
+		return nil
+	}
+	text := d.describe(ident)
+	if text == "" {
+		return nil
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + text + "\n```"},
+		Range:    d.identRange(ident),
+	}
+}
+
+func (s *Server) definition(d *document, pos ast.Position) interface{} {
+	ident := d.identAt(pos)
+	if ident == nil || d.refs[ident] == nil {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.identRange(d.refs[ident].Name)}
+}
+
+var keywords = []string{"fn", "let", "true", "false", "if", "else", "return", "import", "export", "macro"}
+
+// 补全项：位置上可以使用的名字、内置函数和关键字。由编辑器按已经输入的前缀过滤
+func (s *Server) completion(d *document, pos ast.Position) interface{} {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	for _, def := range d.namesAt(pos) {
+		item := CompletionItem{Label: def.Name.Value, Kind: CompletionVariable}
+		switch def.Kind {
+		case lint.ImportBinding:
+			item.Kind = CompletionModule
+		case lint.LetBinding:
+			switch def.Value.(type) {
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				item.Kind = CompletionFunction
+			}
+		}
+		item.Detail = d.describeDefinition(def)
+		items = append(items, item)
+		seen[def.Name.Value] = true
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		if seen[name] {
+			continue
+		}
+		arity, _ := evaluator.BuiltinArity(name)
+		items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: "builtin, takes " + describeArity(arity)})
+	}
+	for _, keyword := range keywords {
+		items = append(items, CompletionItem{Label: keyword, Kind: CompletionKeyword})
+	}
+	return items
+}
+
+// 格式化整个文档。有语法错误时不修改
+func (s *Server) format(d *document) []TextEdit {
+	formatted, err := formatter.Format(d.text)
+	if err != nil || formatted == d.text {
+		return []TextEdit{}
+	}
+	last := len(d.lines) - 1
+	end := Position{Line: last, Character: len(utf16.Encode([]rune(d.lines[last])))}
+	return []TextEdit{{Range: Range{End: end}, NewText: formatted}}
+}
@@ -0,0 +1,357 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///test.mk"
+
+// 依次发送消息给服务器，返回服务器输出的所有消息
+func runSession(t *testing.T, messages ...string) []map[string]interface{} {
+	t.Helper()
+	var in bytes.Buffer
+	for _, msg := range messages {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Run(); err != nil {
+		t.Fatalf("Run() returned error: %s", err)
+	}
+
+	result := []map[string]interface{}{}
+	r := bufio.NewReader(&out)
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err == io.EOF {
+			return result
+		}
+		if err != nil {
+			t.Fatalf("bad header: %s", err)
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			t.Fatalf("bad message: %s", err)
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("bad json %q: %s", data, err)
+		}
+		result = append(result, msg)
+	}
+}
+
+func openMessage(text string) string {
+	data, _ := json.Marshal(text)
+	return fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":%q,"languageId":"monkey","version":1,"text":%s}}}`,
+		testURI, data)
+}
+
+func positionRequest(id int, method string, line, character int) string {
+	return fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":{"textDocument":{"uri":%q},"position":{"line":%d,"character":%d}}}`,
+		id, method, testURI, line, character)
+}
+
+// 把消息转换为 JSON 以便比较
+func toJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestLifecycle(t *testing.T) {
+	messages := runSession(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"unknown/method","params":{}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	if len(messages) != 3 {
+		t.Fatalf("wrong number of messages. got=%d", len(messages))
+	}
+	capabilities := toJSON(messages[0]["result"].(map[string]interface{})["capabilities"])
+	for _, want := range []string{`"hoverProvider":true`, `"definitionProvider":true`, `"documentSymbolProvider":true`,
+		`"completionProvider":{}`, `"documentFormattingProvider":true`, `"textDocumentSync":1`} {
+		if !strings.Contains(capabilities, want) {
+			t.Errorf("capabilities %s do not contain %s", capabilities, want)
+		}
+	}
+	if got := toJSON(messages[1]["error"]); got != `{"code":-32601,"message":"method not found: unknown/method"}` {
+		t.Errorf("wrong error for unknown method: %s", got)
+	}
+	if got := toJSON(messages[2]); got != `{"id":3,"jsonrpc":"2.0","result":null}` {
+		t.Errorf("wrong shutdown response: %s", got)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	msg := `{"jsonrpc":"2.0","method":"exit"}`
+	in := strings.NewReader(fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(msg), msg))
+	if err := NewServer(in, io.Discard).Run(); err != ErrExitWithoutShutdown {
+		t.Errorf("Run() returned %v, want ErrExitWithoutShutdown", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; puts(x);", `[]`},
+		{"let x = ;", `[{"message":"no prefix parse function for ; found","range":{"end":{"character":9,"line":0},"start":{"character":8,"line":0}},"severity":1,"source":"monkey"}]`},
+		{"let unused = 1;\nlen(1, 2);", `[` +
+			`{"code":"unused","message":"unused declared and not used","range":{"end":{"character":10,"line":0},"start":{"character":4,"line":0}},"severity":2,"source":"monkey lint"},` +
+			`{"code":"arity","message":"len called with 2 arguments, want 1","range":{"end":{"character":3,"line":1},"start":{"character":0,"line":1}},"severity":2,"source":"monkey lint"}]`},
+	}
+	for _, tt := range tests {
+		messages := runSession(t, openMessage(tt.input))
+		if len(messages) != 1 || messages[0]["method"] != "textDocument/publishDiagnostics" {
+			t.Fatalf("expected publishDiagnostics, got %v", messages)
+		}
+		params := messages[0]["params"].(map[string]interface{})
+		if params["uri"] != testURI {
+			t.Errorf("wrong uri %v", params["uri"])
+		}
+		if got := toJSON(params["diagnostics"]); got != tt.expected {
+			t.Errorf("wrong diagnostics for %q.\ngot= %s\nwant=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestDidChangeAndClose(t *testing.T) {
+	messages := runSession(t,
+		openMessage("let x = ;"),
+		fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":%q,"version":2},"contentChanges":[{"text":"puts(1);"}]}}`, testURI),
+		positionRequest(1, "textDocument/hover", 0, 1),
+		fmt.Sprintf(`{"jsonrpc":"2.0","method":"textDocument/didClose","params":{"textDocument":{"uri":%q}}}`, testURI),
+		positionRequest(2, "textDocument/hover", 0, 1),
+	)
+	if len(messages) != 5 {
+		t.Fatalf("wrong number of messages. got=%d: %v", len(messages), messages)
+	}
+	if got := toJSON(messages[1]["params"].(map[string]interface{})["diagnostics"]); got != "[]" {
+		t.Errorf("diagnostics were not updated after change: %s", got)
+	}
+	if messages[2]["result"] == nil {
+		t.Errorf("expected hover for changed document")
+	}
+	if got := toJSON(messages[3]["params"].(map[string]interface{})["diagnostics"]); got != "[]" {
+		t.Errorf("diagnostics were not cleared after close: %s", got)
+	}
+	if got := toJSON(messages[4]["error"]); got != `{"code":-32602,"message":"unknown document: file:///test.mk"}` {
+		t.Errorf("wrong error for closed document: %s", got)
+	}
+}
+
+const testProgram = `let limit = 10;
+let add = fn(a, b) { a + b };
+let ratio = 1.5 * limit;
+let name = "monkey" + "!";
+let total = add(limit, 2);
+let 变量 = len(name);
+puts(add(limit, ratio), 变量, total);`
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{0, 5, "let limit: INTEGER"},
+		{1, 4, "let add = fn(a, b)"},
+		{1, 13, "(parameter) a"},
+		{1, 21, "(parameter) a"},
+		{2, 5, "let ratio: FLOAT"},
+		{3, 4, "let name: STRING"},
+		{4, 4, "let total"},
+		{5, 4, "let 变量: INTEGER"},
+		{5, 9, "(builtin) len: BUILTIN, takes 1 argument, returns INTEGER"},
+		{6, 0, "(builtin) puts: BUILTIN, takes at least 0 arguments, returns NULL"},
+		{6, 24, "let 变量: INTEGER"},
+		{6, 28, "let total"},
+		{2, 12, ""}, // 运算符上没有悬停信息
+	}
+	for _, tt := range tests {
+		messages := runSession(t, openMessage(testProgram), positionRequest(1, "textDocument/hover", tt.line, tt.character))
+		result := messages[1]["result"]
+		if tt.expected == "" {
+			if result != nil {
+				t.Errorf("expected no hover at %d:%d, got %v", tt.line, tt.character, result)
+			}
+			continue
+		}
+		if result == nil {
+			t.Errorf("no hover at %d:%d", tt.line, tt.character)
+			continue
+		}
+		contents := result.(map[string]interface{})["contents"].(map[string]interface{})
+		want := "```monkey\n" + tt.expected + "\n```"
+		if contents["value"] != want {
+			t.Errorf("wrong hover at %d:%d. got=%q, want=%q", tt.line, tt.character, contents["value"], want)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	input := "let x = 1;\nlet f = fn(x, y) {\n  let z = x + y;\n  z * x\n};\nf(x, 2);\nlen(x);"
+	tests := []struct {
+		line, character int
+		expected        string // 定义位置 "行:列-列"，空字符串表示没有定义
+	}{
+		{5, 0, "1:4-5"},    // f
+		{5, 2, "0:4-5"},    // 顶层的 x
+		{2, 10, "1:11-12"}, // 参数 x
+		{2, 14, "1:14-15"}, // 参数 y
+		{3, 2, "2:6-7"},    // 局部变量 z
+		{3, 6, "1:11-12"},
+		{0, 4, "0:4-5"}, // 定义处跳转到自己
+		{6, 0, ""},      // 内置函数
+		{6, 5, "0:4-5"},
+	}
+	for _, tt := range tests {
+		messages := runSession(t, openMessage(input), positionRequest(1, "textDocument/definition", tt.line, tt.character))
+		result := messages[1]["result"]
+		if tt.expected == "" {
+			if result != nil {
+				t.Errorf("expected no definition at %d:%d, got %v", tt.line, tt.character, result)
+			}
+			continue
+		}
+		if result == nil {
+			t.Errorf("no definition at %d:%d", tt.line, tt.character)
+			continue
+		}
+		location := result.(map[string]interface{})
+		r := location["range"].(map[string]interface{})
+		start := r["start"].(map[string]interface{})
+		end := r["end"].(map[string]interface{})
+		got := fmt.Sprintf("%v:%v-%v", start["line"], start["character"], end["character"])
+		if got != tt.expected || location["uri"] != testURI {
+			t.Errorf("wrong definition at %d:%d. got=%s, want=%s", tt.line, tt.character, got, tt.expected)
+		}
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	input := "import \"lib/strings.mk\" as s;\nlet x = 1;\nexport let f = fn(a) {\n  let inner = a;\n  if (a) { let deep = 1; }\n  inner\n};\n"
+	messages := runSession(t, openMessage(input),
+		fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"textDocument/documentSymbol","params":{"textDocument":{"uri":%q}}}`, testURI))
+	symbols := []string{}
+	var collect func(prefix string, list interface{})
+	collect = func(prefix string, list interface{}) {
+		if list == nil {
+			return
+		}
+		for _, item := range list.([]interface{}) {
+			symbol := item.(map[string]interface{})
+			r := symbol["range"].(map[string]interface{})
+			symbols = append(symbols, fmt.Sprintf("%s%s kind=%v detail=%v range=%v-%v", prefix, symbol["name"], symbol["kind"],
+				symbol["detail"], toJSON(r["start"]), toJSON(r["end"])))
+			collect(prefix+"  ", symbol["children"])
+		}
+	}
+	collect("", messages[1]["result"])
+	expected := []string{
+		`s kind=2 detail=<nil> range={"character":27,"line":0}-{"character":28,"line":0}`,
+		`x kind=13 detail=INTEGER range={"character":0,"line":1}-{"character":9,"line":1}`,
+		`f kind=12 detail=fn(a) range={"character":7,"line":2}-{"character":1,"line":6}`,
+		`  inner kind=13 detail=<nil> range={"character":2,"line":3}-{"character":15,"line":3}`,
+		`  deep kind=13 detail=INTEGER range={"character":11,"line":4}-{"character":23,"line":4}`,
+	}
+	if strings.Join(symbols, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong symbols.\ngot:\n%s\nwant:\n%s", strings.Join(symbols, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	input := "let top = 1;\nlet f = fn(param) {\n  let local = param;\n  \n  local\n};\nlet later = 2;\n"
+	labels := func(line, character int) map[string]float64 {
+		messages := runSession(t, openMessage(input), positionRequest(1, "textDocument/completion", line, character))
+		result := map[string]float64{}
+		for _, item := range messages[1]["result"].([]interface{}) {
+			item := item.(map[string]interface{})
+			result[item["label"].(string)] = item["kind"].(float64)
+		}
+		return result
+	}
+
+	// 函数体内：参数、之前的局部变量、外层的所有名字
+	inside := labels(3, 2)
+	for name, kind := range map[string]float64{
+		"top": CompletionVariable, "f": CompletionFunction, "later": CompletionVariable,
+		"param": CompletionVariable, "local": CompletionVariable,
+		"len": CompletionFunction, "if": CompletionKeyword,
+	} {
+		if inside[name] != kind {
+			t.Errorf("completion inside function: %s has kind %v, want %v", name, inside[name], kind)
+		}
+	}
+
+	// 顶层：只有之前定义的名字，函数内的名字不可见
+	top := labels(6, 0)
+	for _, name := range []string{"top", "f"} {
+		if _, ok := top[name]; !ok {
+			t.Errorf("completion at top level: missing %s", name)
+		}
+	}
+	for _, name := range []string{"later", "param", "local"} {
+		if _, ok := top[name]; ok {
+			t.Errorf("completion at top level: unexpected %s", name)
+		}
+	}
+}
+
+func TestCompletionWithSyntaxErrors(t *testing.T) {
+	input := "let value = 1;\nlet f = fn(arg) {\n  puts(va\n"
+	messages := runSession(t, openMessage(input), positionRequest(1, "textDocument/completion", 2, 9))
+	found := map[string]bool{}
+	for _, item := range messages[1]["result"].([]interface{}) {
+		found[item.(map[string]interface{})["label"].(string)] = true
+	}
+	if !found["value"] || !found["arg"] {
+		t.Errorf("completion in incomplete document is missing names: %v", found)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	request := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"textDocument/formatting","params":{"textDocument":{"uri":%q},"options":{"tabSize":4,"insertSpaces":true}}}`, testURI)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2\nputs( x )", `[{"newText":"let x = 1 + 2;\nputs(x);\n","range":{"end":{"character":9,"line":1},"start":{"character":0,"line":0}}}]`},
+		{"let x = 1;\n", `[]`},
+		{"let x = ;", `[]`},
+	}
+	for _, tt := range tests {
+		messages := runSession(t, openMessage(tt.input), request)
+		if got := toJSON(messages[1]["result"]); got != tt.expected {
+			t.Errorf("wrong edits for %q.\ngot= %s\nwant=%s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestPositionConversion(t *testing.T) {
+	d := newDocument(testURI, "let s = \"😀\"; let t = s;")
+	// 😀 在 UTF-16 中占两个编码单元
+	if got := d.toLSP(ast.Position{Line: 1, Column: 19}); got != (Position{Line: 0, Character: 19}) {
+		t.Errorf("toLSP = %+v", got)
+	}
+	if got := d.fromLSP(Position{Line: 0, Character: 19}); got != (ast.Position{Line: 1, Column: 19}) {
+		t.Errorf("fromLSP = %+v", got)
+	}
+	if got := d.fromLSP(Position{Line: 0, Character: 23}); got != (ast.Position{Line: 1, Column: 23}) {
+		t.Errorf("fromLSP = %+v", got)
+	}
+	if got := d.toLSP(ast.Position{Line: 1, Column: 23}); got != (Position{Line: 0, Character: 23}) {
+		t.Errorf("toLSP = %+v", got)
+	}
+}
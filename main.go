@@ -1,13 +1,56 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"monkey/evaluator"
+	"monkey/interp"
 	"monkey/repl"
 	"os"
 	"os/user"
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "fmt":
+			os.Exit(runFmt(os.Args[2:]))
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "lsp":
+			os.Exit(runLsp(os.Args[2:]))
+		case "debug":
+			os.Exit(runDebug(os.Args[2:]))
+		case "profile":
+			os.Exit(runProfile(os.Args[2:]))
+		case "cover":
+			os.Exit(runCover(os.Args[2:]))
+		case "test":
+			os.Exit(runTest(os.Args[2:]))
+		}
+	}
+
+	flags := flag.NewFlagSet("monkey", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey [--allow-*] [file]\n")
+		fmt.Fprintf(flags.Output(), "       monkey fmt [--check] [--write] [path ...]\n")
+		fmt.Fprintf(flags.Output(), "       monkey lint path ...\n")
+		fmt.Fprintf(flags.Output(), "       monkey lsp\n")
+		fmt.Fprintf(flags.Output(), "       monkey debug [--allow-*] file\n")
+		fmt.Fprintf(flags.Output(), "       monkey profile [--allow-*] [-o file] [-top n] file\n")
+		fmt.Fprintf(flags.Output(), "       monkey cover [--allow-*] [-lcov file] [-html file] file\n")
+		fmt.Fprintf(flags.Output(), "       monkey test [--allow-*] [-run regexp] [-v] [path ...]\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(flags.Output(), "modules under the script's directory can always be imported; --allow-import also allows other paths\n")
+	}
+	capabilities := capabilityFlags(flags)
+	flags.Parse(os.Args[1:])
+
+	// 带文件参数时执行脚本，否则进入 REPL
+	if flags.NArg() > 0 {
+		os.Exit(runFile(flags.Arg(0), capabilities()))
+	}
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -17,3 +60,34 @@ func main() {
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// 执行代码文件，出错时返回非零的退出码
+func runFile(path string, caps evaluator.Capability) int {
+	if _, err := interp.New(interp.WithCapabilities(caps)).RunFile(path); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// 注册 --allow-all 和每种能力的 --allow-* 参数，返回的函数在解析参数后计算授予的能力
+func capabilityFlags(flags *flag.FlagSet) func() evaluator.Capability {
+	allowAll := flags.Bool("allow-all", false, "grant every capability to the script")
+	allow := map[string]*bool{}
+	for _, name := range evaluator.CapabilityNames() {
+		allow[name] = flags.Bool("allow-"+name, false, "grant the "+name+" capability to the script")
+	}
+	return func() evaluator.Capability {
+		caps := evaluator.CapNone
+		if *allowAll {
+			caps = evaluator.CapAll
+		}
+		for name, granted := range allow {
+			if *granted {
+				cap, _ := evaluator.ParseCapability(name)
+				caps |= cap
+			}
+		}
+		return caps
+	}
+}
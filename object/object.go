@@ -4,7 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
 	"monkey/ast"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -13,6 +16,7 @@ type ObjectType string
 // 对象类型枚举
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -22,6 +26,9 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	MODULE_OBJ       = "MODULE"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
 )
 
 // monkey语言里面的值，都实现了Object接口
@@ -38,6 +45,21 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// 浮点数
+type Float struct {
+	Value float64
+}
+
+// 整数值的浮点数也带上小数点，如 1.0，以便和整数区分
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !math.IsInf(f.Value, 0) && !math.IsNaN(f.Value) && !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
 // 布尔
 type Boolean struct {
 	Value bool
@@ -83,6 +105,20 @@ func NewEnvironment() *Environment {
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	dir   string // 代码文件所在目录，用于解析相对路径的 import
+}
+
+// 设置代码文件所在的目录
+func (e *Environment) SetDir(dir string) {
+	e.dir = dir
+}
+
+// 代码文件所在的目录，没有设置时沿外层环境查找
+func (e *Environment) Dir() string {
+	if e.dir == "" && e.outer != nil {
+		return e.outer.Dir()
+	}
+	return e.dir
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -97,6 +133,21 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// 当前这一层环境中定义的名字，按字母排序，不包括外层环境
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 外层环境，最外层的环境返回 nil
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
 // 函数
 type Function struct {
 	Parameters []*ast.Identifier
@@ -187,6 +238,44 @@ type HashPair struct {
 // hashMap
 type Hash struct {
 	Pairs map[HashKey]HashPair // 使用go的map结构来实现
+	Keys  []HashKey            // key 的插入顺序
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// 设置 key 对应的值，新的 key 追加到插入顺序的末尾
+func (h *Hash) Set(key Hashable, value Object) {
+	if h.Pairs == nil {
+		h.Pairs = make(map[HashKey]HashPair)
+	}
+	hashed := key.HashKey()
+	if _, ok := h.Pairs[hashed]; !ok {
+		h.Keys = append(h.Keys, hashed)
+	}
+	h.Pairs[hashed] = HashPair{Key: key, Value: value}
+}
+
+// 按插入顺序返回所有键值对。
+// 直接写入 Pairs 而没有记录在 Keys 里的键值对排在最后
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	seen := make(map[HashKey]bool, len(h.Keys))
+	for _, key := range h.Keys {
+		if pair, ok := h.Pairs[key]; ok && !seen[key] {
+			seen[key] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	if len(pairs) < len(h.Pairs) {
+		for key, pair := range h.Pairs {
+			if !seen[key] {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	return pairs
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -194,7 +283,7 @@ func (h *Hash) Type() ObjectType { return HASH_OBJ }
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.OrderedPairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Value.Inspect()))
 	}
@@ -204,6 +293,49 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// 模块，import 的结果，只包含导出的绑定
+type Module struct {
+	Path    string
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "<module " + m.Path + ">" }
+
+// quote 的结果，包装一个未求值的 ast 节点
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// 宏，参数和返回值都是 Quote
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
@@ -0,0 +1,36 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/internal/fuzzseed"
+	"monkey/lexer"
+	"testing"
+)
+
+// 任何输入都不能让语法分析 panic，解析结果（包括有错误时的部分结果）可以安全地遍历和输出
+func FuzzParseProgram(f *testing.F) {
+	seeds, err := fuzzseed.Load(".", "../conformance/testdata")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Add("[1][")
+	f.Add("fn(a){}(")
+	f.Fuzz(func(t *testing.T, input string) {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != len(p.ErrorPositions()) {
+			t.Fatalf("%d errors but %d error positions", len(p.Errors()), len(p.ErrorPositions()))
+		}
+		_ = program.String()
+		ast.Inspect(program, func(node ast.Node) bool {
+			if node != nil {
+				_ = node.String()
+				ast.Pos(node)
+			}
+			return true
+		})
+	})
+}
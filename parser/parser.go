@@ -18,7 +18,7 @@ const (
 	PRODUCT     // *
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
-	INDEX
+	INDEX       // array[index] or obj.member
 )
 
 var precedences = map[token.TokenType]int{
@@ -32,8 +32,21 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
+// 中缀运算符（包括调用、下标和成员访问）的优先级，其他 token 返回 LOWEST
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
+// 表达式最多嵌套的层数。求值和遍历语法树都是递归的，
+// 嵌套过深的代码会耗尽 go 的栈，而栈溢出无法 recover
+const maxNesting = 10000
+
 type (
 	// 处理前缀表达式
 	prefixParseFn func() ast.Expression
@@ -47,7 +60,12 @@ type Parser struct {
 	curToken  token.Token // 当前的token
 	peekToken token.Token // 下一个token
 
-	errors []string
+	errors    []string
+	positions []Error // 与 errors 一一对应，带有出错的位置
+
+	depth   int  // 当前所在代码块的嵌套层数，0 表示在顶层
+	nesting int  // 当前所在表达式的嵌套层数
+	aborted bool // 表达式嵌套过深，已跳过剩下的代码
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
@@ -64,6 +82,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
@@ -76,6 +95,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
@@ -92,23 +112,62 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	return p
 }
 
+// parse 下标表达式 a[i]，遇到冒号时转为切片表达式 a[start:end:step]
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	var index ast.Expression
+	if !p.curTokenIs(token.COLON) {
+		index = p.parseExpression(LOWEST)
+		if !p.peekTokenIs(token.COLON) {
+			if !p.expectPeek(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+		}
+		p.nextToken()
+	}
+	// 当前 token 是第一个冒号
+	slice := &ast.SliceExpression{Token: tok, Left: left, Start: index}
+	slice.End = p.parseSliceBound()
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		slice.Step = p.parseSliceBound()
+	}
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	return slice
+}
+
+// parse 冒号后面可以省略的切片边界
+func (p *Parser) parseSliceBound() ast.Expression {
+	if p.peekTokenIs(token.COLON) || p.peekTokenIs(token.RBRACKET) {
+		return nil
+	}
+	p.nextToken()
+	return p.parseExpression(LOWEST)
+}
+
+// parse 成员访问 obj.name
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	return exp
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.End = p.curToken
 	return array
 }
 
@@ -162,11 +221,26 @@ func (p *Parser) ParseProgram() *ast.Program {
 // 从当前的token位置 parse一条声明
 func (p *Parser) parseStatement() ast.Statement {
 	// defer untrace(trace("parseStatement"))
+	// 解析失败时返回 nil 接口，而不是包含 nil 指针的接口，
+	// 否则出错的语句会被加入到语法树中
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.IMPORT:
+		if stmt := p.parseImportStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.EXPORT:
+		if stmt := p.parseExportStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -190,7 +264,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
-	for !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -205,12 +279,70 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parse import 声明
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if p.peekTokenIs(token.LBRACE) {
+		// import { a, b } from "path"
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		for p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		}
+		if !p.expectPeek(token.RBRACE) || !p.expectPeekKeyword("from") || !p.expectPeek(token.STRING) {
+			return nil
+		}
+		stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	} else {
+		// import "path" as m
+		if !p.expectPeek(token.STRING) {
+			return nil
+		}
+		stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+		if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "as" {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		}
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
 }
 
+// parse export 声明，只能导出顶层的 let 绑定
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	if p.depth > 0 {
+		p.addError(p.curToken, "export is only allowed at the top level")
+		return nil
+	}
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
 // 当前token类似是否符合预期
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
@@ -232,14 +364,50 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// 下一个token是否是指定的上下文关键字（如 as、from），它们不是保留字，仍可以作为变量名
+func (p *Parser) expectPeekKeyword(keyword string) bool {
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == keyword {
+		p.nextToken()
+		return true
+	}
+	msg := fmt.Sprintf("expected next token to be %q, got %s instead",
+		keyword, p.peekToken.Type)
+	p.addError(p.peekToken, msg)
+	return false
+}
+
 func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// 带位置的语法错误
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e Error) String() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// 和 Errors 相同，但带有出错 token 的位置，供编辑器等工具使用
+func (p *Parser) ErrorPositions() []Error {
+	return p.positions
+}
+
+func (p *Parser) addError(tok token.Token, msg string) {
+	if p.aborted {
+		return
+	}
+	p.errors = append(p.errors, msg)
+	p.positions = append(p.positions, Error{Line: tok.Line, Column: tok.Column, Message: msg})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -265,6 +433,12 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 // 先按前缀解析左边，再判断后面有没符合条件的中缀
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	// defer untrace(trace("parseExpression"))
+	p.nesting++
+	defer func() { p.nesting-- }()
+	if p.nesting > maxNesting {
+		p.abortTooDeep()
+		return nil
+	}
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
@@ -273,17 +447,31 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	// 先用前缀方法解析左边的表达式
 	leftExp := prefix()
 	// 如果后面有级别更高的操作符，则按中缀解析，前面的表达式当做中缀表达式的一分部
-	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
+	// 左结合的中缀表达式每次循环都让语法树深一层，同样计入嵌套层数
+	for levels := 1; !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence(); levels++ {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			return leftExp
 		}
+		if p.nesting+levels > maxNesting {
+			p.abortTooDeep()
+			return nil
+		}
 		p.nextToken()
 		leftExp = infix(leftExp)
 	}
 	return leftExp
 }
 
+// 报告嵌套过深的错误并跳过剩下的代码，之后的错误都是它引起的，不再报告
+func (p *Parser) abortTooDeep() {
+	p.addError(p.curToken, fmt.Sprintf("exceeded max depth of %d nested expressions", maxNesting))
+	p.aborted = true
+	for !p.curTokenIs(token.EOF) {
+		p.nextToken()
+	}
+}
+
 // 解析Identifier表达式
 func (p *Parser) parseIdentifier() ast.Expression {
 	// defer untrace(trace("parseIdentifier"))
@@ -296,7 +484,20 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+// parse浮点数
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 	lit.Value = value
@@ -305,7 +506,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
 
 // parse 前缀表达式
@@ -395,6 +596,8 @@ func (p *Parser) parseIfExpression() ast.Expression {
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	p.depth++
+	defer func() { p.depth-- }()
 	p.nextToken()
 	// 不断尝试parse一个新的声明，直到结束
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
@@ -404,6 +607,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.End = p.curToken
 	return block
 }
 
@@ -421,6 +625,20 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+// parse宏定义，和函数定义的语法相同
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken} // macro
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	lit.Parameters = p.parseFunctionParameters()
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	lit.Body = p.parseBlockStatement()
+	return lit
+}
+
 // parse函数的参数
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
@@ -447,6 +665,7 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.End = p.curToken
 	return exp
 }
 
@@ -462,6 +681,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
 		}
@@ -469,5 +689,6 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.End = p.curToken
 	return hash
 }
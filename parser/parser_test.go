@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"strings"
 	"testing"
 )
 
@@ -70,6 +71,27 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	t.FailNow()
 }
 
+func TestStatementsWithoutSemicolon(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5", "let x = 5;"},
+		{"return x", "return x;"},
+		{"let x = 5\nlet y = x", "let x = 5;let y = x;"},
+		{"fn() { return 1 }", "fn() return 1;"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("program.String() wrong. expected=%q, got=%q",
+				tt.expected, program.String())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	input := `
 	return 5;
@@ -153,6 +175,50 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "2.5;"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 2.5 {
+		t.Errorf("literal.Value not %f. got=%f", 2.5, literal.Value)
+	}
+
+	// 浮点数和整数的优先级相同，小数点后不是数字时仍然是成员访问
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-0.25", "(-0.25)"},
+		{"1.5 * 2 + 0.5", "((1.5 * 2) + 0.5)"},
+		{"[1.50, 2][0]", "([1.50, 2][0])"},
+		{"1.x", "(1.x)"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%s: wrong program. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestHashLiteralKeyOrder(t *testing.T) {
+	input := `{"c": 1, "a": 2, "b": 3}`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if got := program.String(); got != `{c:1, a:2, b:3}` {
+		t.Errorf("program.String() wrong. got=%q", got)
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	tt := []struct {
 		input    string
@@ -576,6 +642,44 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T",
+			stmt.Expression)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d\n",
+			len(macro.Parameters))
+	}
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statements. got=%d\n",
+			len(macro.Body.Statements))
+	}
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T",
+			macro.Body.Statements[0])
+	}
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -771,3 +875,161 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:2]", "(a[:2])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::-1]", "(a[::(-1)])"},
+		{"a[1:n - 1:2]", "(a[1:(n - 1):2])"},
+		{"a[1][2:]", "((a[1])[2:])"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestImportExportStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/math.mk" as m;`, `import "lib/math.mk" as m;`},
+		{`import "math.mk"`, `import "math.mk";`},
+		{`import { add, sub } from "math.mk";`, `import { add, sub } from "math.mk";`},
+		{`export let x = 5;`, `export let x = 5;`},
+		{`m.add(1, 2)`, `(m.add)(1, 2)`},
+		{`a.b.c`, `((a.b).c)`},
+		{`let as = 1; let from = 2;`, `let as = 1;let from = 2;`},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestImportExportErrors(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{`import m;`, "expected next token to be STRING, got IDENT instead"},
+		{`import { a } "m.mk";`, `expected next token to be "from", got STRING instead`},
+		{`import "m.mk" as 1;`, "expected next token to be IDENT, got INT instead"},
+		{`fn() { export let x = 1; }`, "export is only allowed at the top level"},
+		{`export fn() {}`, "expected next token to be LET, got FUNCTION instead"},
+		{`a.1`, "expected next token to be IDENT, got INT instead"},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expectedError {
+			t.Errorf("input %q: expected error %q, got=%q", tt.input, tt.expectedError, errors)
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	input := "let x 5;\nlet = 10;\nlet y = 1 +;"
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	expected := []string{
+		"1:7: expected next token to be =, got INT instead",
+		"2:5: expected next token to be IDENT, got = instead",
+		"2:5: no prefix parse function for = found",
+		"3:12: no prefix parse function for ; found",
+	}
+	positions := p.ErrorPositions()
+	if len(positions) != len(p.Errors()) {
+		t.Fatalf("len(ErrorPositions()) = %d, len(Errors()) = %d", len(positions), len(p.Errors()))
+	}
+	for i, e := range positions {
+		if e.Message != p.Errors()[i] {
+			t.Errorf("positions[%d].Message = %q, want %q", i, e.Message, p.Errors()[i])
+		}
+	}
+	if len(positions) != len(expected) {
+		t.Fatalf("wrong number of errors. got=%v", positions)
+	}
+	for i, want := range expected {
+		if got := positions[i].String(); got != want {
+			t.Errorf("positions[%d] = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestFailedStatementsAreNotAdded(t *testing.T) {
+	p := New(lexer.New(`let = 1; import; export 5; let x = 2;`))
+	program := p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	for i, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let == nil {
+			t.Errorf("program.Statements[%d] is a nil *ast.LetStatement", i)
+		}
+		if imp, ok := stmt.(*ast.ImportStatement); ok && imp == nil {
+			t.Errorf("program.Statements[%d] is a nil *ast.ImportStatement", i)
+		}
+		if exp, ok := stmt.(*ast.ExportStatement); ok && exp == nil {
+			t.Errorf("program.Statements[%d] is a nil *ast.ExportStatement", i)
+		}
+	}
+}
+
+// 嵌套过深的表达式报告一个解析错误，而不是耗尽栈
+func TestMaxNesting(t *testing.T) {
+	n := maxNesting + 1
+	tests := []struct {
+		input   string
+		tooDeep bool
+	}{
+		{strings.Repeat("(", n) + "1" + strings.Repeat(")", n), true},
+		{strings.Repeat("!", n) + "true", true},
+		{strings.Repeat("[", n) + strings.Repeat("]", n), true},
+		{strings.Repeat("if (true) { ", n) + strings.Repeat("}", n), true},
+		{"1" + strings.Repeat(" + 1", n), true},
+		{"f" + strings.Repeat("()", n), true},
+		{"let x = 1;\n" + strings.Repeat("-", n) + "x; let y = ;", true},
+		{strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100), false},
+		{"1" + strings.Repeat(" + 1", 100), false},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if !tt.tooDeep {
+			if len(errors) != 0 {
+				t.Errorf("input %.20q...: unexpected errors %q", tt.input, errors)
+			}
+			continue
+		}
+		// 只报告嵌套过深的错误，之后的代码被跳过
+		expected := fmt.Sprintf("exceeded max depth of %d nested expressions", maxNesting)
+		if len(errors) != 1 || errors[0] != expected {
+			t.Errorf("input %.20q...: expected error %q, got=%q", tt.input, expected, errors)
+		}
+	}
+}
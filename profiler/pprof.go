@@ -0,0 +1,187 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// profile.proto 中的字段编号，见 github.com/google/pprof/proto/profile.proto
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// 按 pprof 的 profile.proto 格式输出 gzip 压缩的结果，可以用 go tool pprof 查看。
+// 每个 sample 是一个调用栈，值为调用次数和自身耗时（纳秒）
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	table := newStringTable()
+	var out protobuf
+
+	for _, vt := range [][2]string{{"calls", "count"}, {"time", "nanoseconds"}} {
+		var m protobuf
+		m.int64(valueTypeType, table.index(vt[0]))
+		m.int64(valueTypeUnit, table.index(vt[1]))
+		out.message(profileSampleType, &m)
+	}
+
+	keys := make([]string, 0, len(p.samples))
+	for key := range p.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := p.samples[key]
+		ids := make([]uint64, len(s.stack))
+		for i, fn := range s.stack {
+			ids[i] = fn.id
+		}
+		var m protobuf
+		m.packed(sampleLocationID, ids)
+		m.packed(sampleValue, []uint64{uint64(s.calls), uint64(s.flat.Nanoseconds())})
+		out.message(profileSample, &m)
+	}
+
+	// 每个函数对应一个 location，位置是函数的 fn 关键字
+	for _, fn := range p.order {
+		var line protobuf
+		line.uint64(lineFunctionID, fn.id)
+		line.int64(lineLine, int64(fn.Line))
+		var m protobuf
+		m.uint64(locationID, fn.id)
+		m.message(locationLine, &line)
+		out.message(profileLocation, &m)
+	}
+	for _, fn := range p.order {
+		// go tool pprof 把 <main> 这样的名字显示为 <unknown>
+		name := fn.Name
+		if fn == p.main {
+			name = "main"
+		}
+		var m protobuf
+		m.uint64(functionID, fn.id)
+		m.int64(functionName, table.index(name))
+		m.int64(functionSystemName, table.index(name))
+		m.int64(functionFilename, table.index(fn.File))
+		m.int64(functionStartLine, int64(fn.Line))
+		out.message(profileFunction, &m)
+	}
+
+	var period protobuf
+	period.int64(valueTypeType, table.index("time"))
+	period.int64(valueTypeUnit, table.index("nanoseconds"))
+	out.message(profilePeriodType, &period)
+	out.int64(profilePeriod, 1)
+	out.int64(profileTimeNanos, p.start.UnixNano())
+	out.int64(profileDurationNanos, p.duration.Nanoseconds())
+
+	for _, s := range table.strings {
+		out.string(profileStringTable, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(out.bytes); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// profile 中的字符串表，第一个必须是空字符串
+type stringTable struct {
+	strings []string
+	indexes map[string]int64
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{strings: []string{""}, indexes: map[string]int64{"": 0}}
+}
+
+func (t *stringTable) index(s string) int64 {
+	if i, ok := t.indexes[s]; ok {
+		return i
+	}
+	i := int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.indexes[s] = i
+	return i
+}
+
+// 最简单的 protobuf 编码，只支持 profile.proto 用到的 varint 和 length-delimited 字段
+type protobuf struct {
+	bytes []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.bytes = append(b.bytes, byte(x)|0x80)
+		x >>= 7
+	}
+	b.bytes = append(b.bytes, byte(x))
+}
+
+func (b *protobuf) key(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+// 值为 0 的字段省略，与 proto3 的默认值一致
+func (b *protobuf) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protobuf) string(field int, s string) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(s)))
+	b.bytes = append(b.bytes, s...)
+}
+
+func (b *protobuf) message(field int, m *protobuf) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(m.bytes)))
+	b.bytes = append(b.bytes, m.bytes...)
+}
+
+func (b *protobuf) packed(field int, xs []uint64) {
+	var m protobuf
+	for _, x := range xs {
+		m.varint(x)
+	}
+	b.message(field, &m)
+}
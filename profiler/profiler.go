@@ -0,0 +1,258 @@
+// profiler 包统计 monkey 函数的调用次数和耗时。
+// 每个函数字面量按源码位置区分，记录调用次数、自身耗时（不含调用的其他函数）和累计耗时；
+// 结果可以输出为文本报告，或 go tool pprof 能读取的 profile 文件
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 一个函数字面量的统计结果
+type Function struct {
+	Name   string // let 绑定的名字，匿名函数为 "fn@行:列"，顶层代码为 "<main>"
+	File   string // 所在文件，不是来自文件的代码为空字符串
+	Line   int    // fn 关键字的位置
+	Column int
+
+	Calls int64
+	Flat  time.Duration // 自身耗时，不包括调用其他函数的时间
+	Cum   time.Duration // 累计耗时，递归调用只计算最外层的一次
+
+	id     uint64 // pprof 中 function 和 location 的 id
+	active int    // 正在执行中的调用数，用于处理递归
+}
+
+// 在报告中显示的位置，顶层代码为空字符串
+func (f *Function) Location() string {
+	if f.Line == 0 {
+		return ""
+	}
+	file := "<input>"
+	if f.File != "" {
+		file = filepath.Base(f.File)
+	}
+	return fmt.Sprintf("%s:%d:%d", file, f.Line, f.Column)
+}
+
+type location struct {
+	file         string
+	line, column int
+}
+
+// 调用栈中的一层
+type frame struct {
+	fn       *Function
+	start    time.Time
+	children time.Duration // 调用的其他函数的耗时
+}
+
+// 同一个调用栈上的耗时，对应 pprof 中的一个 sample
+type sample struct {
+	stack []*Function // 第一个是最内层的函数
+	calls int64
+	flat  time.Duration
+}
+
+type Profiler struct {
+	evaluator.NopHooks
+	now func() time.Time
+
+	literals  map[*ast.BlockStatement]*Function // 函数体对应的函数，由 LoadProgram 建立
+	functions map[location]*Function
+	order     []*Function // 按第一次调用的顺序
+	main      *Function
+	stack     []*frame
+	samples   map[string]*sample
+
+	start    time.Time
+	duration time.Duration
+}
+
+// 创建分析器并设置为 e 的钩子，调用 Start 之后开始统计
+func New(e *evaluator.Evaluator) *Profiler {
+	p := &Profiler{
+		now:       time.Now,
+		literals:  map[*ast.BlockStatement]*Function{},
+		functions: map[location]*Function{},
+		samples:   map[string]*sample{},
+	}
+	p.main = p.function(location{}, "<main>")
+	e.SetHooks(p)
+	return p
+}
+
+// 开始统计，之后的耗时计入顶层代码
+func (p *Profiler) Start() {
+	p.start = p.now()
+	p.stack = []*frame{{fn: p.main, start: p.start}}
+	p.main.Calls++
+	p.main.active++
+}
+
+// 停止统计。执行被中止时还没有返回的函数也在这里结束
+func (p *Profiler) Stop() {
+	for len(p.stack) > 0 {
+		p.exit()
+	}
+	p.duration = p.main.Cum
+}
+
+// 总耗时
+func (p *Profiler) Duration() time.Duration {
+	return p.duration
+}
+
+// 被调用过的函数，按自身耗时从高到低排序，顶层代码也在其中
+func (p *Profiler) Functions() []*Function {
+	functions := make([]*Function, 0, len(p.order))
+	for _, fn := range p.order {
+		if fn.Calls > 0 {
+			functions = append(functions, fn)
+		}
+	}
+	sort.SliceStable(functions, func(i, j int) bool {
+		if functions[i].Flat != functions[j].Flat {
+			return functions[i].Flat > functions[j].Flat
+		}
+		return functions[i].Cum > functions[j].Cum
+	})
+	return functions
+}
+
+func (p *Profiler) function(loc location, name string) *Function {
+	if fn, ok := p.functions[loc]; ok {
+		return fn
+	}
+	fn := &Function{Name: name, File: loc.file, Line: loc.line, Column: loc.column, id: uint64(len(p.order) + 1)}
+	p.functions[loc] = fn
+	p.order = append(p.order, fn)
+	return fn
+}
+
+// evaluator.Hooks
+
+func (p *Profiler) LoadProgram(program *ast.Program, path string) {
+	names := map[*ast.FunctionLiteral]string{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			if fl, ok := node.Value.(*ast.FunctionLiteral); ok && node.Name != nil {
+				names[fl] = node.Name.Value
+			}
+		case *ast.FunctionLiteral:
+			name, ok := names[node]
+			if !ok {
+				name = fmt.Sprintf("fn@%d:%d", node.Token.Line, node.Token.Column)
+			}
+			loc := location{path, node.Token.Line, node.Token.Column}
+			p.literals[node.Body] = p.function(loc, name)
+		}
+		return true
+	})
+}
+
+func (p *Profiler) EnterFunction(fn *object.Function, call *ast.CallExpression, env *object.Environment) {
+	if len(p.stack) == 0 {
+		return
+	}
+	f, ok := p.literals[fn.Body]
+	if !ok {
+		// 没有经过 LoadProgram 的代码，用函数体的位置表示
+		line, column := fn.Body.Token.Line, fn.Body.Token.Column
+		f = p.function(location{"", line, column}, fmt.Sprintf("fn@%d:%d", line, column))
+		p.literals[fn.Body] = f
+	}
+	f.Calls++
+	f.active++
+	p.stack = append(p.stack, &frame{fn: f, start: p.now()})
+}
+
+func (p *Profiler) ExitFunction(fn *object.Function, result object.Object) {
+	if len(p.stack) > 1 {
+		p.exit()
+	}
+}
+
+// 结束调用栈最内层的函数，把耗时计入函数和调用栈
+func (p *Profiler) exit() {
+	top := p.stack[len(p.stack)-1]
+	elapsed := p.now().Sub(top.start)
+	self := elapsed - top.children
+
+	top.fn.Flat += self
+	top.fn.active--
+	if top.fn.active == 0 {
+		top.fn.Cum += elapsed
+	}
+
+	ids := make([]string, len(p.stack))
+	stack := make([]*Function, len(p.stack))
+	for i, f := range p.stack {
+		ids[len(p.stack)-1-i] = fmt.Sprint(f.fn.id)
+		stack[len(p.stack)-1-i] = f.fn
+	}
+	key := strings.Join(ids, ",")
+	s, ok := p.samples[key]
+	if !ok {
+		s = &sample{stack: stack}
+		p.samples[key] = s
+	}
+	s.calls++
+	s.flat += self
+
+	p.stack = p.stack[:len(p.stack)-1]
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].children += elapsed
+	}
+}
+
+// 输出文本报告，top 大于 0 时只输出自身耗时最高的 top 个函数
+func (p *Profiler) WriteText(w io.Writer, top int) error {
+	functions := p.Functions()
+	if top > 0 && len(functions) > top {
+		functions = functions[:top]
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "total time %s\n", formatDuration(p.duration))
+	fmt.Fprintf(&out, "%10s %12s %7s %12s %7s  %s\n", "calls", "flat", "flat%", "cum", "cum%", "function")
+	for _, fn := range functions {
+		fmt.Fprintf(&out, "%10d %12s %6.2f%% %12s %6.2f%%  %s\n",
+			fn.Calls, formatDuration(fn.Flat), p.percent(fn.Flat),
+			formatDuration(fn.Cum), p.percent(fn.Cum), strings.TrimSpace(fn.Name+" "+fn.Location()))
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+// 把 pprof 格式的结果写入文件
+func (p *Profiler) WriteProfileFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.WriteProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (p *Profiler) percent(d time.Duration) float64 {
+	if p.duration <= 0 {
+		return 0
+	}
+	return float64(d) / float64(p.duration) * 100
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,206 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"monkey/interp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 每次读取时间前进 1ms 的时钟，使耗时只取决于调用的次数
+func fakeClock() func() time.Time {
+	t := time.Unix(0, 0)
+	return func() time.Time {
+		t = t.Add(time.Millisecond)
+		return t
+	}
+}
+
+func profile(t *testing.T, src string) *Profiler {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.mk")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	i := interp.New(interp.WithStdout(io.Discard))
+	p := New(i.Evaluator())
+	p.now = fakeClock()
+	p.Start()
+	if _, err := i.RunFile(path); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	return p
+}
+
+func TestProfile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // 名字 位置 调用次数 自身耗时 累计耗时
+	}{
+		{
+			// 时钟：Start 1，twice 2-5（add 3-4），twice 6-9（add 7-8），Stop 10
+			"let add = fn(a, b) { a + b };\nlet twice = fn(x) { add(x, x) };\ntwice(1);\ntwice(2);",
+			[]string{
+				"twice main.mk:2:13 2 4ms 6ms",
+				"<main>  1 3ms 9ms",
+				"add main.mk:1:11 2 2ms 2ms",
+			},
+		},
+		{
+			// 递归调用的累计耗时只计算最外层的调用
+			"let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } };\nf(2);",
+			[]string{
+				"f main.mk:1:9 3 5ms 5ms",
+				"<main>  1 2ms 7ms",
+			},
+		},
+		{
+			// 匿名函数用位置命名，由内置函数回调的也会统计
+			"map([1, 2], fn(x) { x });",
+			[]string{
+				"<main>  1 3ms 5ms",
+				"fn@1:13 main.mk:1:13 2 2ms 2ms",
+			},
+		},
+	}
+	for _, tt := range tests {
+		p := profile(t, tt.input)
+		got := []string{}
+		for _, fn := range p.Functions() {
+			got = append(got, fmt.Sprintf("%s %s %d %s %s", fn.Name, fn.Location(), fn.Calls, fn.Flat, fn.Cum))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong profile for %q.\ngot:\n%s\nwant:\n%s", tt.input, strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+		}
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	p := profile(t, "let add = fn(a, b) { a + b };\nlet twice = fn(x) { add(x, x) };\ntwice(1);\ntwice(2);")
+	var out bytes.Buffer
+	if err := p.WriteText(&out, 2); err != nil {
+		t.Fatal(err)
+	}
+	expected := `total time 9.000ms
+     calls         flat   flat%          cum    cum%  function
+         2      4.000ms  44.44%      6.000ms  66.67%  twice main.mk:2:13
+         1      3.000ms  33.33%      9.000ms 100.00%  <main>
+`
+	if out.String() != expected {
+		t.Errorf("wrong report.\ngot:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+// 解码 protobuf 消息的字段，varint 字段的值放在 varint，length-delimited 字段的值放在 bytes
+type field struct {
+	number int
+	varint uint64
+	bytes  []byte
+}
+
+func decode(t *testing.T, data []byte) []field {
+	t.Helper()
+	varint := func() uint64 {
+		var x uint64
+		for shift := 0; ; shift += 7 {
+			if len(data) == 0 {
+				t.Fatalf("truncated varint")
+			}
+			b := data[0]
+			data = data[1:]
+			x |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				return x
+			}
+		}
+	}
+	fields := []field{}
+	for len(data) > 0 {
+		key := varint()
+		f := field{number: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			f.varint = varint()
+		case wireBytes:
+			n := varint()
+			f.bytes, data = data[:n], data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func TestWriteProfile(t *testing.T) {
+	p := profile(t, "let add = fn(a, b) { a + b };\nlet twice = fn(x) { add(x, x) };\ntwice(1);\ntwice(2);")
+	var out bytes.Buffer
+	if err := p.WriteProfile(&out); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strs := []string{}
+	samples := [][]field{}
+	functions := [][]field{}
+	for _, f := range decode(t, data) {
+		switch f.number {
+		case profileStringTable:
+			strs = append(strs, string(f.bytes))
+		case profileSample:
+			samples = append(samples, decode(t, f.bytes))
+		case profileFunction:
+			functions = append(functions, decode(t, f.bytes))
+		case profileDurationNanos:
+			if f.varint != uint64(9*time.Millisecond) {
+				t.Errorf("wrong duration %d", f.varint)
+			}
+		}
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("string table must start with an empty string: %q", strs)
+	}
+
+	names := []string{}
+	for _, fn := range functions {
+		for _, f := range fn {
+			if f.number == functionName {
+				names = append(names, strs[f.varint])
+			}
+		}
+	}
+	if got := strings.Join(names, " "); got != "main add twice" {
+		t.Errorf("wrong function names %s", got)
+	}
+
+	// 调用栈 main、main>twice、main>twice>add 各一个 sample
+	stacks := []string{}
+	for _, sample := range samples {
+		for _, f := range sample {
+			if f.number == sampleLocationID {
+				ids := []string{}
+				for _, id := range f.bytes {
+					ids = append(ids, fmt.Sprint(id))
+				}
+				stacks = append(stacks, strings.Join(ids, ","))
+			}
+		}
+	}
+	if got := strings.Join(stacks, " "); got != "1 2,3,1 3,1" {
+		t.Errorf("wrong sample stacks %s", got)
+	}
+}
@@ -6,8 +6,8 @@ import (
 	"io"
 	"monkey/evaluator"
 	"monkey/lexer"
-	"monkey/parser"
 	"monkey/object"
+	"monkey/parser"
 )
 
 const PROMPT = ">> "
@@ -15,8 +15,13 @@ const PROMPT = ">> "
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	eval := evaluator.New()
+	eval.SetStdout(out)
+	// 交互式使用时授予全部能力
+	eval.SetCapabilities(evaluator.CapAll)
 	for {
-		fmt.Print(PROMPT)
+		fmt.Fprint(out, PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
@@ -29,7 +34,13 @@ func Start(in io.Reader, out io.Writer) {
 			printParserErrors(out, p.Errors())
 			continue
 		}
-		evaluated := evaluator.Eval(program,env)
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, err := eval.ExpandMacros(program, macroEnv)
+		if err != nil {
+			io.WriteString(out, "macro expansion failed: "+err.Error()+"\n")
+			continue
+		}
+		evaluated := eval.Eval(expanded, env)
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -0,0 +1,161 @@
+// testrunner 包运行用 monkey 编写的测试。
+// 测试文件以 _test.mk 结尾，其中每个名字以 test_ 开头的顶层函数是一个测试，
+// 测试中用 assert、assert_eq 等内置函数检查结果，函数返回错误即为失败。
+// 每个测试在新的解释器中重新执行测试文件后调用，测试之间互不影响
+package testrunner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/interp"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"strings"
+	"time"
+)
+
+// 测试文件中的一个测试函数
+type Test struct {
+	Name string
+	Line int
+}
+
+// 一个测试的结果
+type Result struct {
+	Test
+	File     string
+	Err      error  // 测试失败的原因，通过时为 nil
+	Output   string // 测试执行时的输出
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool { return r.Err == nil }
+
+// 测试文件中的测试函数，按在文件中的顺序
+func Tests(program *ast.Program) []Test {
+	tests := []Test{}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Statement
+		}
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || let.Name == nil || !strings.HasPrefix(let.Name.Value, "test_") {
+			continue
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			tests = append(tests, Test{Name: let.Name.Value, Line: let.Token.Line})
+		}
+	}
+	return tests
+}
+
+type Runner struct {
+	Options []interp.Option        // 创建解释器的选项，如授予的能力
+	Match   func(name string) bool // 只运行名字满足条件的测试，nil 表示全部运行
+	Verbose bool                   // 同时输出通过的测试
+	Out     io.Writer              // 结果输出的位置
+}
+
+// 运行测试文件中的测试，文件无法读取或解析时返回错误
+func (r *Runner) RunFile(path string) ([]Result, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &interp.ParseError{Messages: p.Errors()}
+	}
+
+	results := []Result{}
+	for _, test := range Tests(program) {
+		if r.Match != nil && !r.Match(test.Name) {
+			continue
+		}
+		results = append(results, r.run(path, test))
+	}
+	return results, nil
+}
+
+// 在新的解释器中执行测试文件，然后调用测试函数
+func (r *Runner) run(path string, test Test) Result {
+	var output bytes.Buffer
+	opts := append([]interp.Option{interp.WithStdout(&output), interp.WithStderr(&output)}, r.Options...)
+	i := interp.New(opts...)
+
+	start := time.Now()
+	_, err := i.RunFile(path)
+	if err == nil {
+		_, err = i.Call(test.Name)
+	}
+	return Result{
+		Test:     test,
+		File:     path,
+		Err:      err,
+		Output:   output.String(),
+		Duration: time.Since(start),
+	}
+}
+
+// 运行所有测试文件中的测试并输出结果，全部通过时返回 true
+func (r *Runner) Run(files []string) bool {
+	ok := true
+	for _, file := range files {
+		start := time.Now()
+		results, err := r.RunFile(file)
+		if err != nil {
+			fmt.Fprintf(r.Out, "FAIL\t%s\t%s\n", file, err)
+			ok = false
+			continue
+		}
+		passed, failed := 0, 0
+		for _, result := range results {
+			if result.Passed() {
+				passed++
+			} else {
+				failed++
+			}
+			if r.Verbose || !result.Passed() {
+				r.report(result)
+			}
+		}
+		elapsed := time.Since(start).Seconds()
+		switch {
+		case failed > 0:
+			fmt.Fprintf(r.Out, "FAIL\t%s\t%d passed, %d failed (%.3fs)\n", file, passed, failed, elapsed)
+			ok = false
+		case passed == 0:
+			fmt.Fprintf(r.Out, "ok  \t%s\tno tests to run\n", file)
+		default:
+			fmt.Fprintf(r.Out, "ok  \t%s\t%d passed (%.3fs)\n", file, passed, elapsed)
+		}
+	}
+	return ok
+}
+
+// 输出一个测试的结果，失败原因和测试的输出缩进显示在下面
+func (r *Runner) report(result Result) {
+	status := "PASS"
+	if !result.Passed() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(r.Out, "--- %s: %s (%s:%d, %.3fs)\n", status, result.Name, result.File, result.Line, result.Duration.Seconds())
+	if result.Err != nil {
+		fmt.Fprint(r.Out, indent(result.Err.Error()))
+	}
+	if result.Output != "" {
+		fmt.Fprint(r.Out, indent("output:\n"+result.Output))
+	}
+}
+
+func indent(s string) string {
+	var out strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		out.WriteString("    " + line + "\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,136 @@
+package testrunner
+
+import (
+	"bytes"
+	"fmt"
+	"monkey/lexer"
+	"monkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testFile = `import { add } from "math.mk";
+puts("loading");
+let test_add = fn() {
+  assert_eq(add(1, 2), 3);
+};
+let test_fail = fn() {
+  puts("computing");
+  assert_eq(add(2, 2), 5, "two plus two");
+};
+export let test_exported = fn() { assert(true) };
+let test_error = fn() { 1 + "a" };
+let helper = fn() { 1 };
+let test_value = 1;
+`
+
+func writeFiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"math.mk":      "export let add = fn(a, b) { a + b };",
+		"math_test.mk": testFile,
+		"bad_test.mk":  "let = fn() {};",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTests(t *testing.T) {
+	program := parser.New(lexer.New(testFile)).ParseProgram()
+	got := []string{}
+	for _, test := range Tests(program) {
+		got = append(got, fmt.Sprintf("%s:%d", test.Name, test.Line))
+	}
+	expected := "test_add:3 test_fail:6 test_exported:10 test_error:11"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("wrong tests %s, want %s", strings.Join(got, " "), expected)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := writeFiles(t)
+	r := &Runner{}
+	results, err := r.RunFile(filepath.Join(dir, "math_test.mk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		name   string
+		err    string
+		output string
+	}{
+		{"test_add", "", "loading\n"},
+		{"test_fail", "assertion failed: two plus two\n--- expected\n+++ actual\n-5\n+4", "loading\ncomputing\n"},
+		{"test_exported", "", "loading\n"},
+		{"test_error", "type mismatch: INTEGER + STRING", "loading\n"},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("wrong number of results %d", len(results))
+	}
+	for i, tt := range expected {
+		result := results[i]
+		if result.Name != tt.name {
+			t.Errorf("result %d: wrong name %s, want %s", i, result.Name, tt.name)
+		}
+		errText := ""
+		if result.Err != nil {
+			errText = result.Err.Error()
+		}
+		if errText != tt.err || result.Passed() != (tt.err == "") {
+			t.Errorf("%s: wrong error %q, want %q", tt.name, errText, tt.err)
+		}
+		// 每个测试都重新执行测试文件，所以都有顶层代码的输出
+		if result.Output != tt.output {
+			t.Errorf("%s: wrong output %q, want %q", tt.name, result.Output, tt.output)
+		}
+	}
+
+	r.Match = func(name string) bool { return strings.Contains(name, "add") }
+	results, _ = r.RunFile(filepath.Join(dir, "math_test.mk"))
+	if len(results) != 1 || results[0].Name != "test_add" {
+		t.Errorf("Match did not filter tests: %v", results)
+	}
+
+	if _, err := r.RunFile(filepath.Join(dir, "bad_test.mk")); err == nil || !strings.HasPrefix(err.Error(), "parser errors") {
+		t.Errorf("expected parser error, got %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := writeFiles(t)
+	var out bytes.Buffer
+	r := &Runner{Out: &out}
+	if r.Run([]string{filepath.Join(dir, "math_test.mk"), filepath.Join(dir, "bad_test.mk")}) {
+		t.Errorf("Run reported success")
+	}
+	for _, want := range []string{
+		"--- FAIL: test_fail (" + filepath.Join(dir, "math_test.mk") + ":6, ",
+		"    assertion failed: two plus two\n    --- expected\n    +++ actual\n    -5\n    +4\n    output:\n    loading\n    computing\n",
+		"--- FAIL: test_error (",
+		"FAIL\t" + filepath.Join(dir, "math_test.mk") + "\t2 passed, 2 failed (",
+		"FAIL\t" + filepath.Join(dir, "bad_test.mk") + "\tparser errors: ",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output does not contain %q.\noutput:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "test_add") {
+		t.Errorf("passing test reported without Verbose:\n%s", out.String())
+	}
+
+	out.Reset()
+	r = &Runner{Out: &out, Verbose: true, Match: func(name string) bool { return name == "test_add" }}
+	if !r.Run([]string{filepath.Join(dir, "math_test.mk")}) {
+		t.Errorf("Run reported failure")
+	}
+	if !strings.Contains(out.String(), "--- PASS: test_add (") || !strings.Contains(out.String(), "ok  \t") {
+		t.Errorf("wrong verbose output:\n%s", out.String())
+	}
+}
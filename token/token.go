@@ -5,14 +5,19 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 所在行，从 1 开始
+	Column  int // 所在列（按字符计），从 1 开始
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
+	COMMENT = "COMMENT" // 注释，不会出现在 NextToken 的结果中
+
 	IDENT = "IDENT"
 	INT   = "INT"
+	FLOAT = "FLOAT"
 
 	// Operators
 	ASSIGN   = "="
@@ -29,7 +34,8 @@ const (
 
 	COMMA     = ","
 	SEMICOLON = ";"
-	COLON = ":"
+	COLON     = ":"
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -45,6 +51,9 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	MACRO    = "MACRO"
 
 	STRING = "STRING"
 )
@@ -58,6 +67,9 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"import": IMPORT,
+	"export": EXPORT,
+	"macro":  MACRO,
 }
 
 // 根据字符查找token类型